@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"docker-ai/pkg/config"
+	"docker-ai/pkg/llm"
+)
+
+// newCache returns the reply cache configured in appConfig.
+func newCache(appConfig config.Config) (*llm.Cache, error) {
+	dir, err := llm.DefaultCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	var ttl time.Duration
+	if appConfig.CacheTTL != "" {
+		ttl, err = time.ParseDuration(appConfig.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache_ttl: %w", err)
+		}
+	}
+	return llm.NewCache(dir, ttl, appConfig.CacheMaxEntries), nil
+}
+
+// runCacheCommand handles `docker-ai cache <subcommand>` and returns the
+// exit status.
+func runCacheCommand(args []string) int {
+	if len(args) != 1 || args[0] != "clear" {
+		fmt.Println("Usage: docker-ai cache clear")
+		return 2
+	}
+
+	dir, err := llm.DefaultCacheDir()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	removed, err := llm.NewCache(dir, 0, 0).Clear()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Removed %d cached replies.\n", removed)
+	return 0
+}
@@ -3,28 +3,72 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"docker-ai/pkg/config"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strings"
 
+	"docker-ai/pkg/audit"
+	"docker-ai/pkg/inspect"
 	"docker-ai/pkg/learning"
 	"docker-ai/pkg/llm"
+	"docker-ai/pkg/usage"
 
 	"github.com/peterh/liner"
 )
 
+// stdin is shared by every prompt that reads a line outside the liner, so
+// input buffered by one isn't lost to the next.
+var stdin = bufio.NewReader(os.Stdin)
+
+// session holds the settings shared by every request in a run.
+type session struct {
+	provider       llm.Provider
+	model          string
+	repairAttempts int
+	// cache is nil when --no-cache is set.
+	cache *llm.Cache
+	usage *usage.Tracker
+	// prompt is the system prompt template, and env the parts of its data
+	// that don't change during a session.
+	prompt *llm.PromptTemplate
+	env    llm.PromptData
+	// tools is nil when a model in use can't call tools. Otherwise it is
+	// inspector, which remembers the objects its output has named.
+	tools     llm.ToolSet
+	inspector *inspect.Tools
+	// candidates is how many commands to ask for (--candidates), and pick
+	// the one to run without asking (--pick), or 0 to show the picker.
+	candidates int
+	pick       int
+}
+
 func main() {
 	if os.Getenv("DOCKER_AI_MODE") == "learn" {
 		runLearningMode()
 		return
 	}
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cache":
+			os.Exit(runCacheCommand(os.Args[2:]))
+		case "usage":
+			os.Exit(runUsageCommand(os.Args[2:]))
+		case "prompt":
+			os.Exit(runPromptCommand(os.Args[2:]))
+		case "models":
+			os.Exit(runModelsCommand(os.Args[2:]))
+		}
+	}
+
 	runAIMode()
 }
 
@@ -74,22 +118,116 @@ func runAIMode() {
 		fmt.Printf("Warning: could not load config file: %v\n", err)
 	}
 
-	llmProvider := flag.String("llm-provider", "groq", "LLM provider to use (groq, gemini, openai)")
-	model := flag.String("model", "gemma-3n-e4b-it", "Model to use")
+	llmProvider := flag.String("llm-provider", "groq", fmt.Sprintf("LLM provider to use (%s)", strings.Join(llm.Providers(), ", ")))
+	model := flag.String("model", "", "Model to use (default: the provider's default; run 'docker-ai models' to list them)")
+	baseURL := flag.String("llm-base-url", "", "Override the provider's API base URL (e.g. http://localhost:8000/v1)")
+	defaultAttempts := llm.DefaultRetryPolicy.MaxAttempts
+	if appConfig.MaxAttempts > 0 {
+		defaultAttempts = appConfig.MaxAttempts
+	}
+	maxAttempts := flag.Int("max-attempts", defaultAttempts, "Maximum number of tries for each LLM request (1 disables retries)")
+	timeout := flag.Duration("timeout", llm.DefaultTimeout, "Timeout for each LLM request (0 for none)")
+	fallbackSpec := flag.String("fallback", "", "Comma-separated provider[:model] list to try in order if the main provider fails (e.g. gemini:gemini-2.5-flash,ollama)")
+	repairAttempts := flag.Int("repair", appConfig.RepairAttempts, "Ask the AI to fix a failed command up to this many times (0 disables)")
+	noCache := flag.Bool("no-cache", false, "Don't read or write the reply cache")
+	candidates := flag.Int("candidates", 0, "Ask for up to this many ranked commands and pick one (0 or 1 for a single command)")
+	pick := flag.Int("pick", 0, "Run the Nth candidate without showing the picker (implies --candidates)")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "Don't check the TLS certificates of LLM providers (unsafe; prefer ca_file in the config)")
 	command := flag.String("c", "", "Execute a single command and exit")
 	flag.Parse()
 
+	if *model == "" {
+		*model = llm.DefaultModel(*llmProvider)
+	}
+	if *pick > 0 && *candidates < *pick {
+		*candidates = max(*pick, defaultCandidates)
+	}
+
+	fallbackChain := appConfig.Fallback
+	if *fallbackSpec != "" {
+		fallbackChain, err = parseFallback(*fallbackSpec)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	settings := providerSettings{
+		baseURL:            *baseURL,
+		timeout:            *timeout,
+		maxAttempts:        *maxAttempts,
+		limiter:            newRateLimiter(&appConfig),
+		insecureSkipVerify: *insecureSkipVerify,
+	}
+	if *insecureSkipVerify {
+		fmt.Fprintln(os.Stderr, "WARNING: --insecure-skip-verify is set. TLS certificates of LLM providers are NOT checked,")
+		fmt.Fprintln(os.Stderr, "WARNING: so anyone on the network can read your prompts and API keys and change the commands the AI suggests.")
+	}
+	settings.wrapTransport, err = cassetteFromEnv()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	provider, err := buildProviderChain(&appConfig, *llmProvider, fallbackChain, settings)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	prompt, err := llm.LoadPromptTemplate(promptPaths()...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	announceProjectPrompt(prompt)
+
+	redactor, err := llm.NewRedactor(appConfig.RedactPatterns)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	provider = llm.WithRedaction(provider, redactor)
+
+	tracker := newUsageTracker(appConfig)
+	s := session{
+		provider: llm.WithUsage(provider, func(provider string, u llm.Usage) {
+			if err := tracker.Add(provider, u); err != nil {
+				fmt.Printf("Warning: could not record token usage: %v\n", err)
+			}
+		}),
+		model:          *model,
+		repairAttempts: *repairAttempts,
+		usage:          tracker,
+		prompt:         prompt,
+		env:            dockerEnvironment(),
+		candidates:     *candidates,
+		pick:           *pick,
+		inspector:      &inspect.Tools{},
+	}
+	links := append([]config.FallbackEntry{{Provider: *llmProvider, Model: *model}}, fallbackChain...)
+	if checkModels(links, provider.Capabilities(), s.candidates) {
+		s.tools = s.inspector
+	}
+	if !*noCache {
+		s.cache, err = newCache(appConfig)
+		if err != nil {
+			fmt.Printf("Warning: reply cache disabled: %v\n", err)
+		}
+	}
+
 	if *command != "" {
-		runSingleCommand(&appConfig, *command, *llmProvider, *model)
+		runSingleCommand(&appConfig, *command, s, nil)
 		return
 	}
 
-	runInteractiveMode(&appConfig, *llmProvider, *model)
+	runInteractiveMode(&appConfig, s)
 }
 
-func runInteractiveMode(appConfig *config.Config, llmProvider, model string) {
+func runInteractiveMode(appConfig *config.Config, s session) {
 	fmt.Println("Docker AI interactive shell. Type 'exit' or 'quit' to leave.")
 
+	conversation := llm.NewConversation(appConfig.HistoryTokenBudget)
+
 	historyFile := filepath.Join(os.Getenv("HOME"), ".docker-ai-history")
 	line := liner.NewLiner()
 	defer line.Close()
@@ -121,10 +259,21 @@ func runInteractiveMode(appConfig *config.Config, llmProvider, model string) {
 			break
 		}
 
+		if input == "/reset" {
+			conversation.Reset()
+			fmt.Println("Conversation history cleared.")
+			continue
+		}
+
+		if input == "/usage" {
+			s.usage.Report(os.Stdout)
+			continue
+		}
+
 		// Before running the command, close the liner to restore the terminal
 		line.Close()
 
-		runSingleCommand(appConfig, input, llmProvider, model)
+		runSingleCommand(appConfig, input, s, conversation)
 
 		// After a command that takes over stdin, the terminal can be left in a
 		// "raw" state. We use `stty` to force it back to a sane mode before
@@ -149,6 +298,8 @@ func runInteractiveMode(appConfig *config.Config, llmProvider, model string) {
 		f.Close()
 	}
 
+	s.usage.Report(os.Stdout)
+
 	if err := config.SaveConfig(*appConfig); err != nil {
 		fmt.Println("Failed to save configuration:", err)
 	} else {
@@ -156,7 +307,7 @@ func runInteractiveMode(appConfig *config.Config, llmProvider, model string) {
 	}
 }
 
-func runSingleCommand(appConfig *config.Config, input, llmProvider, model string) {
+func runSingleCommand(appConfig *config.Config, input string, s session, conversation *llm.Conversation) {
 	if input == "reset confirm" {
 		appConfig.SkipCleanupWarning = false
 		if err := config.SaveConfig(*appConfig); err != nil {
@@ -173,98 +324,149 @@ func runSingleCommand(appConfig *config.Config, input, llmProvider, model string
 		userInput = strings.ReplaceAll(input, "that container", appConfig.LastContainerName)
 	}
 
-	// Get running containers to provide context to the LLM
-	var fullPrompt string
-	psCmd := exec.Command("sh", "-c", "docker ps -a --format '{{.Names}} ({{.Status}})'")
-	psOutput, err := psCmd.CombinedOutput()
-	if err == nil && len(psOutput) > 0 {
-		containerList := strings.TrimSpace(string(psOutput))
-		containerList = strings.ReplaceAll(containerList, "\n", ", ")
-		fullPrompt = fmt.Sprintf("The user wants to perform a Docker command. Here is a list of all containers (running and stopped): %s.\n\nUser's request: %s", containerList, userInput)
-	} else {
-		fullPrompt = userInput
-	}
-
-	if !strings.Contains(userInput, "model") {
-		fullPrompt += "\n\nNote: The 'docker model' command is not available on this system."
-	}
-
-	response, err := llm.QueryLLM(fullPrompt, llmProvider, model)
+	// Get all containers to provide context to the LLM
+	data := s.env
+	data.Containers, _ = inspect.Containers(context.Background())
+	systemPrompt, err := s.prompt.Render(data)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
-	if !strings.HasPrefix(response, "docker ") {
-		fmt.Println(response)
+	// Whatever happens below is remembered for follow-up requests.
+	turn := llm.Turn{Request: userInput, ExitCode: -1}
+	defer func() {
+		if turn.Command != "" || turn.Answer != "" {
+			conversation.Add(turn)
+		}
+	}()
+
+	containers := data.ContainerNames()
+	result, ok := askLLM(s, conversation, systemPrompt, containers, userInput)
+	if !ok {
 		return
 	}
 
-	// Cleanup command confirmation
-	if isCleanupCommand(response) && !appConfig.SkipCleanupWarning {
-		fmt.Printf("WARNING: The generated command is a cleanup command:\n%s\n\n", response)
-
-		// For single-command mode, we need a way to confirm.
-		// We'll use a simple prompt here, but this could be improved.
-		// A liner isn't running, so we use fmt.
-		fmt.Print("Are you sure you want to execute? [y]es, [n]o, [d]on't ask again: ")
-		reader := bufio.NewReader(os.Stdin)
-		answer, _ := reader.ReadString('\n')
-
-		answer = strings.ToLower(strings.TrimSpace(answer))
-
-		switch answer {
-		case "y", "yes":
-			// continue to execution
-		case "d", "dont", "don't ask again":
-			appConfig.SkipCleanupWarning = true
-			if err := config.SaveConfig(*appConfig); err != nil {
-				fmt.Println("Failed to save configuration:", err)
+	// Each failed command is sent back to the model for a correction, up to
+	// s.repairAttempts times. Every attempt is audited and confirmed like the
+	// first.
+	var failures []llm.FailedCommand
+	for {
+		reply := result.Reply
+		if len(reply.Candidates) > 1 || (s.pick > 1 && reply.Command != "") {
+			i, ok := pickCandidate(reply.Candidates, s.pick)
+			if !ok {
+				fmt.Println("Execution cancelled.")
+				return
+			}
+			c := reply.Candidates[i]
+			picked := &llm.Reply{Command: c.Command, RiskLevel: c.RiskLevel}
+			// The picker has already shown the explanations.
+			if s.pick > 0 {
+				picked.Explanation = c.Explanation
+			}
+			reply = picked
+		}
+		switch {
+		case reply.NeedsClarification:
+			turn.Answer = reply.Question
+			if !result.Streamed {
+				fmt.Println(reply.Question)
+			}
+			return
+		case reply.Command == "":
+			turn.Answer = reply.Explanation
+			if !result.Streamed {
+				fmt.Println(reply.Explanation)
 			}
-			// continue to execution
-		default:
-			fmt.Println("Execution cancelled.")
 			return
+		case reply.Explanation != "" && !result.Streamed:
+			fmt.Println(reply.Explanation)
+		}
+		response := reply.Command
+		turn.Command, turn.ExitCode = response, -1
+
+		// Names the user never mentioned may have been planted in the
+		// container list or tool output to steer the model. Only what the
+		// user wrote counts as mentioned, not the model's earlier commands.
+		targets := append(inspect.ContainerTargets(context.Background()), s.inspector.Seen()...)
+		unmentioned := llm.UnmentionedTargets(response, targets, userInput, conversation.Mentioned())
+
+		if err := audit.Append(audit.Entry{
+			Request:     input,
+			Command:     response,
+			Provider:    result.Provider,
+			Model:       result.Model,
+			Repair:      len(failures),
+			Unmentioned: unmentioned,
+		}); err != nil {
+			fmt.Printf("Warning: could not write audit log: %v\n", err)
 		}
-	}
 
-	fmt.Printf("➜ executing: %s\n", response)
+		if !confirmCommand(appConfig, response, reply.RiskLevel, unmentioned) {
+			fmt.Println("Execution cancelled.")
+			return
+		}
 
-	// Execute the Docker command
-	var stderrBuf bytes.Buffer
-	cmd := exec.Command("sh", "-c", response)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
-	err = cmd.Run()
+		fmt.Printf("➜ executing: %s\n", response)
+
+		// Execute the Docker command
+		var stderrBuf bytes.Buffer
+		cmd := exec.Command("sh", "-c", response)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+		err = cmd.Run()
+		if cmd.ProcessState != nil {
+			turn.ExitCode = cmd.ProcessState.ExitCode()
+		}
+		if err == nil {
+			break
+		}
 
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			// The command exited with a non-zero status.
-			// Stderr is already printed. We can analyze it from the buffer.
-			stderrString := stderrBuf.String()
-			if strings.Contains(response, "docker model") {
-				if strings.Contains(stderrString, "is not a docker command") {
-					fmt.Println("\nError: The 'docker model' command is not available.")
-					fmt.Println("Please ensure you have installed the Docker Model Runner plugin.")
-					fmt.Println("You can try installing it with: 'sudo apt-get update && sudo apt-get install docker-model-plugin'")
-				}
-			}
-			// Check for docker scout update message
-			if strings.Contains(stderrString, "New version") && strings.Contains(stderrString, "available") {
-				fmt.Println("\nHint: A new version of Docker Scout is available.")
-				fmt.Println("To update it, you can run the following command in your terminal:")
-				fmt.Println("curl -sSfL https://raw.githubusercontent.com/docker/scout-cli/main/install.sh | sh -s --")
-			}
-			fmt.Printf("\nCommand finished with error: %s\n", exitErr)
-		} else {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
 			fmt.Printf("Error executing command: %v\n", err)
+			return
+		}
+		// The command exited with a non-zero status.
+		// Stderr is already printed. We can analyze it from the buffer.
+		stderrString := stderrBuf.String()
+		if strings.Contains(response, "docker model") {
+			if strings.Contains(stderrString, "is not a docker command") {
+				fmt.Println("\nError: The 'docker model' command is not available.")
+				fmt.Println("Please ensure you have installed the Docker Model Runner plugin.")
+				fmt.Println("You can try installing it with: 'sudo apt-get update && sudo apt-get install docker-model-plugin'")
+			}
+		}
+		// Check for docker scout update message
+		if strings.Contains(stderrString, "New version") && strings.Contains(stderrString, "available") {
+			fmt.Println("\nHint: A new version of Docker Scout is available.")
+			fmt.Println("To update it, you can run the following command in your terminal:")
+			fmt.Println("curl -sSfL https://raw.githubusercontent.com/docker/scout-cli/main/install.sh | sh -s --")
+		}
+		fmt.Printf("\nCommand finished with error: %s\n", exitErr)
+
+		// Don't serve a command that failed from the cache again.
+		s.cache.Forget(result.CacheKey)
+		if len(failures) >= s.repairAttempts {
+			return
+		}
+		failures = append(failures, llm.FailedCommand{
+			Command:  response,
+			ExitCode: turn.ExitCode,
+			Stderr:   stderrString,
+		})
+		fmt.Printf("\nAsking the AI for a fix (repair attempt %d of %d)...\n", len(failures), s.repairAttempts)
+		// A repair asks for the one corrected command, not a new choice.
+		s.candidates, s.pick = 0, 0
+		if result, ok = askLLM(s, conversation, systemPrompt, containers, llm.RepairPrompt(userInput, failures)); !ok {
+			return
 		}
-		return
 	}
 
 	// If the command was a 'docker run', get and store the container name
-	if strings.HasPrefix(response, "docker run") {
+	if strings.HasPrefix(turn.Command, "docker run") {
 		// With streaming, we can't just get the container ID from the output of this command.
 		// We will need a new way to get the container name.
 		// A simple approach is to list recent containers and assume the latest one is it.
@@ -288,4 +490,98 @@ func runSingleCommand(appConfig *config.Config, input, llmProvider, model string
 			fmt.Print(string(statusOut))
 		}
 	}
-} 
\ No newline at end of file
+}
+
+// askLLM sends prompt to the provider with the given system prompt, which
+// lists containers, and prints any error. Ctrl-C while waiting for the model
+// cancels only this request, so the interactive shell survives a slow or
+// hung provider. Prose that was streamed is followed by a newline.
+func askLLM(s session, conversation *llm.Conversation, systemPrompt string, containers []string, prompt string) (*llm.Result, bool) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := conversation.Compact(ctx, s.provider, s.model); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	result, err := llm.QueryLLM(ctx, s.provider, llm.Query{
+		SystemPrompt: systemPrompt,
+		Prompt:       prompt,
+		Model:        s.model,
+		Conversation: conversation,
+		Cache:        s.cache,
+		Candidates:   s.candidates,
+		Containers:   containers,
+		Out:          os.Stdout,
+		Tools:        s.tools,
+		OnToolCall: func(call llm.ToolCall) {
+			fmt.Printf("(checking %s)\n", inspect.Describe(call))
+		},
+	})
+	if result.Streamed {
+		fmt.Println()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Println("Request cancelled.")
+		} else {
+			fmt.Printf("Error: %v\n", err)
+		}
+		return nil, false
+	}
+	if result.Cached {
+		fmt.Printf("(cached reply from %s)\n", result.Provider)
+	}
+	return result, true
+}
+
+// confirmCommand asks before running cleanup commands, and any command the
+// model rated high risk, unless the user turned the warning off. The
+// model's own risk rating is trusted to raise the alarm, but never to lower
+// it below the regex check. unmentioned lists the command's targets that
+// the user never named: they are pointed out, and a command aimed at them
+// that changes anything always asks, whatever the user's setting. Whether
+// it does is decided by llm.ChangesState alone, since the model that picked
+// the target also picked the risk level.
+func confirmCommand(appConfig *config.Config, command, riskLevel string, unmentioned []string) bool {
+	destructive := isCleanupCommand(command) || riskLevel == llm.RiskHigh
+	if len(unmentioned) > 0 {
+		fmt.Printf("Note: the command targets %s, which you didn't mention.\n", strings.Join(unmentioned, ", "))
+		if destructive || llm.ChangesState(command) {
+			fmt.Printf("WARNING: This command changes something that came from Docker's output, not from you:\n%s\n\n", command)
+			fmt.Print("Are you sure you want to execute? [y]es, [n]o: ")
+			answer, _ := stdin.ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			return answer == "y" || answer == "yes"
+		}
+	}
+
+	if !destructive || appConfig.SkipCleanupWarning {
+		return true
+	}
+	if isCleanupCommand(command) {
+		fmt.Printf("WARNING: The generated command is a cleanup command:\n%s\n\n", command)
+	} else {
+		fmt.Printf("WARNING: The generated command was rated high risk:\n%s\n\n", command)
+	}
+
+	// For single-command mode, we need a way to confirm.
+	// We'll use a simple prompt here, but this could be improved.
+	// A liner isn't running, so we use fmt.
+	fmt.Print("Are you sure you want to execute? [y]es, [n]o, [d]on't ask again: ")
+	answer, _ := stdin.ReadString('\n')
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	switch answer {
+	case "y", "yes":
+		return true
+	case "d", "dont", "don't ask again":
+		appConfig.SkipCleanupWarning = true
+		if err := config.SaveConfig(*appConfig); err != nil {
+			fmt.Println("Failed to save configuration:", err)
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"docker-ai/pkg/config"
+	"docker-ai/pkg/llm"
+)
+
+func TestConfirmUnmentionedTarget(t *testing.T) {
+	tests := []struct {
+		command   string
+		riskLevel string
+		answer    string
+		want      bool
+	}{
+		// A command that changes state asks, whatever the model rated it.
+		{command: "docker container rm -f planted", riskLevel: llm.RiskLow, answer: "n\n", want: false},
+		{command: "docker container rm -f planted", riskLevel: llm.RiskLow, answer: "y\n", want: true},
+		{command: "docker stop planted", riskLevel: llm.RiskLow, answer: "\n", want: false},
+		// Read-only commands only get the note.
+		{command: "docker logs --tail 20 planted", riskLevel: llm.RiskLow, want: true},
+	}
+	for _, tt := range tests {
+		stdin = bufio.NewReader(strings.NewReader(tt.answer))
+		// "Don't ask again" doesn't cover targets from Docker's output.
+		appConfig := &config.Config{SkipCleanupWarning: true}
+		if got := confirmCommand(appConfig, tt.command, tt.riskLevel, []string{"planted"}); got != tt.want {
+			t.Errorf("confirmCommand(%q) with answer %q = %v, want %v", tt.command, tt.answer, got, tt.want)
+		}
+	}
+}
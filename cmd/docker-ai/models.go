@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"docker-ai/pkg/config"
+	"docker-ai/pkg/llm"
+)
+
+// checkModels warns about each provider and model in the chain that lacks
+// something a feature needs, where caps says the providers themselves
+// support it. Models missing from the catalog are assumed to be capable. It
+// reports whether tools can be offered: the inspect tools have to be left
+// out if any model can't call them.
+func checkModels(links []config.FallbackEntry, caps llm.Capabilities, candidates int) (toolCalling bool) {
+	toolCalling = caps.ToolCalling
+	for _, link := range links {
+		model := link.Model
+		if model == "" {
+			model = llm.DefaultModel(link.Provider)
+		}
+		info, ok := llm.LookupModel(link.Provider, model)
+		if !ok {
+			continue
+		}
+		if caps.ToolCalling && !info.ToolCalling {
+			toolCalling = false
+			fmt.Printf("Warning: %s (%s) can't call tools, so the AI won't inspect containers before answering.\n", model, link.Provider)
+		}
+		if candidates > 1 && !info.JSONMode {
+			fmt.Printf("Warning: %s (%s) has no JSON mode, which --candidates relies on; it may offer only one command.\n", model, link.Provider)
+		}
+	}
+	return toolCalling
+}
+
+// runModelsCommand handles `docker-ai models [provider]`, which lists the
+// model catalog, and returns the exit status.
+func runModelsCommand(args []string) int {
+	if len(args) > 1 {
+		fmt.Println("Usage: docker-ai models [provider]")
+		return 2
+	}
+	provider := ""
+	if len(args) == 1 {
+		provider = args[0]
+		if !slices.Contains(llm.Providers(), provider) {
+			fmt.Printf("Error: unknown provider %q (available: %v)\n", provider, llm.Providers())
+			return 2
+		}
+	}
+
+	models := llm.Models(provider)
+	if len(models) == 0 {
+		fmt.Printf("The catalog has no models for %s.\n", provider)
+		return 0
+	}
+
+	// Prices in the config file override the catalog, as in cost estimates.
+	appConfig, err := config.LoadConfig()
+	if err != nil {
+		fmt.Printf("Warning: could not load config file: %v\n", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tMODEL\tCONTEXT\tJSON\tTOOLS\tPRICE IN/OUT PER 1M TOKENS")
+	for _, m := range models {
+		name := m.Name
+		if m.Default {
+			name += " *"
+		}
+		price := m.Price
+		if p, ok := appConfig.Prices[m.Name]; ok {
+			price = &p
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", m.Provider, name, m.ContextWindow, yesNo(m.JSONMode), yesNo(m.ToolCalling), formatPrice(price))
+	}
+	tw.Flush()
+	fmt.Println("\n* default model, used when --model isn't given")
+	return 0
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func formatPrice(p *llm.Price) string {
+	switch {
+	case p == nil:
+		return "unknown"
+	case p.Input == 0 && p.Output == 0:
+		return "free"
+	default:
+		return formatDollars(p.Input) + " / " + formatDollars(p.Output)
+	}
+}
+
+// formatDollars shows cents, or tenths of a cent for cheaper models.
+func formatDollars(v float64) string {
+	if cents := v * 100; math.Abs(cents-math.Round(cents)) < 1e-9 {
+		return fmt.Sprintf("$%.2f", v)
+	}
+	return fmt.Sprintf("$%.3f", v)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"docker-ai/pkg/llm"
+)
+
+// defaultCandidates is how many commands --pick asks for when --candidates
+// isn't given.
+const defaultCandidates = 3
+
+// pickCandidate returns the index of the candidate to run. With pick set
+// (--pick), that candidate is taken without asking; otherwise the user
+// chooses from a numbered list. ok is false if nothing should run.
+func pickCandidate(candidates []llm.Candidate, pick int) (index int, ok bool) {
+	if pick > 0 {
+		if pick > len(candidates) {
+			fmt.Printf("Error: --pick %d, but the model offered only %d candidate(s).\n", pick, len(candidates))
+			return 0, false
+		}
+		return pick - 1, true
+	}
+
+	fmt.Println("Candidate commands:")
+	for i, c := range candidates {
+		fmt.Printf("  %d. %s  [%s risk]\n", i+1, c.Command, c.RiskLevel)
+		if c.Explanation != "" {
+			fmt.Printf("     %s\n", c.Explanation)
+		}
+	}
+
+	for {
+		fmt.Printf("Pick a command [1-%d], or press Enter to cancel: ", len(candidates))
+		answer, err := stdin.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			return 0, false
+		}
+		if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 1 && n <= len(candidates) {
+			return n - 1, true
+		}
+		if err != nil {
+			return 0, false
+		}
+		fmt.Printf("Please enter a number from 1 to %d.\n", len(candidates))
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"docker-ai/pkg/inspect"
+	"docker-ai/pkg/llm"
+)
+
+// promptFile is the name of a system prompt override, in the home directory
+// or the current one.
+const promptFile = ".docker-ai-prompt.tmpl"
+
+// promptPaths lists the system prompt overrides in the order they apply:
+// the user's, then the project's. Run from the home directory, they are
+// the same file and it is listed once.
+func promptPaths() []string {
+	var paths []string
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, promptFile))
+		if cwd, err := os.Getwd(); err == nil && cwd == home {
+			return paths
+		}
+	}
+	return append(paths, promptFile)
+}
+
+// announceProjectPrompt notes on stderr that the project's prompt override
+// applies. A file in whatever directory docker-ai runs from can replace the
+// whole system prompt, including its rules about untrusted data, so it must
+// never apply silently.
+func announceProjectPrompt(prompt *llm.PromptTemplate) {
+	for _, source := range prompt.Sources {
+		if source != promptFile {
+			continue
+		}
+		if abs, err := filepath.Abs(source); err == nil {
+			source = abs
+		}
+		fmt.Fprintf(os.Stderr, "Using %s\n", source)
+	}
+}
+
+// dockerEnvironment collects the prompt data that stays the same for a
+// whole session.
+func dockerEnvironment() llm.PromptData {
+	ctx := context.Background()
+	return llm.PromptData{
+		DockerVersion: inspect.Version(ctx),
+		Plugins:       inspect.Plugins(ctx),
+	}
+}
+
+// runPromptCommand handles `docker-ai prompt show`, which prints the system
+// prompt as it would be sent right now, and returns the exit status.
+func runPromptCommand(args []string) int {
+	if len(args) != 1 || args[0] != "show" {
+		fmt.Println("Usage: docker-ai prompt show")
+		return 2
+	}
+
+	prompt, err := llm.LoadPromptTemplate(promptPaths()...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	data := dockerEnvironment()
+	data.Containers, _ = inspect.Containers(context.Background())
+	text, err := prompt.Render(data)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	// The sources go to stderr so the prompt itself can be redirected to a
+	// file and used as the starting point for an override.
+	for _, source := range prompt.Sources {
+		fmt.Fprintf(os.Stderr, "Using %s\n", source)
+	}
+	fmt.Print(text)
+	return 0
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"docker-ai/pkg/cassette"
+	"docker-ai/pkg/config"
+	"docker-ai/pkg/llm"
+)
+
+// providerSettings are the command-line flags that apply to every provider.
+type providerSettings struct {
+	baseURL     string
+	timeout     time.Duration
+	maxAttempts int
+	// wrapTransport is set when a cassette is recording or replaying.
+	wrapTransport func(http.RoundTripper) http.RoundTripper
+	// limiter is shared by every provider in the chain.
+	limiter *llm.RateLimiter
+	// insecureSkipVerify turns off TLS certificate checks for every
+	// provider.
+	insecureSkipVerify bool
+}
+
+// newProvider builds a provider from its config file settings, with rate
+// limits and retries.
+// The --llm-base-url flag only applies to the main provider, so it is passed
+// in separately as baseURL.
+func newProvider(appConfig *config.Config, name, baseURL string, settings providerSettings) (llm.Provider, error) {
+	providerConfig := appConfig.Providers[name]
+	if baseURL != "" {
+		providerConfig.BaseURL = baseURL
+	}
+
+	transport, err := llm.NewTransport(llm.TransportOptions{
+		Proxy:              providerConfig.Proxy,
+		CAFile:             providerConfig.CAFile,
+		ClientCert:         providerConfig.ClientCert,
+		ClientKey:          providerConfig.ClientKey,
+		InsecureSkipVerify: settings.insecureSkipVerify,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	provider, err := llm.NewProvider(name, llm.Options{
+		BaseURL:       providerConfig.BaseURL,
+		AuthHeader:    providerConfig.AuthHeader,
+		AuthScheme:    providerConfig.AuthScheme,
+		APIKeyEnv:     providerConfig.APIKeyEnv,
+		Deployment:    providerConfig.Deployment,
+		APIVersion:    providerConfig.APIVersion,
+		RulesFile:     providerConfig.RulesFile,
+		Timeout:       settings.timeout,
+		Transport:     transport,
+		Headers:       providerConfig.Headers,
+		WrapTransport: settings.wrapTransport,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	provider = llm.WithRateLimit(provider, settings.limiter, func(provider string, wait time.Duration) {
+		fmt.Fprintf(os.Stderr, "%s rate limit reached, waiting %s...\n", provider, wait.Round(100*time.Millisecond))
+	})
+
+	retryPolicy := llm.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = settings.maxAttempts
+	retryPolicy.OnRetry = func(attempt int, wait time.Duration, err error) {
+		fmt.Fprintf(os.Stderr, "%s request failed (%s), retrying in %s (attempt %d of %d)...\n",
+			name, failureReason(err), wait.Round(time.Second), attempt, settings.maxAttempts)
+	}
+	return llm.WithRetry(provider, retryPolicy), nil
+}
+
+// buildProviderChain builds the main provider followed by its fallbacks.
+func buildProviderChain(appConfig *config.Config, name string, fallbacks []config.FallbackEntry, settings providerSettings) (llm.Provider, error) {
+	primary, err := newProvider(appConfig, name, settings.baseURL, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	links := []llm.ChainLink{{Provider: primary}}
+	for _, entry := range fallbacks {
+		provider, err := newProvider(appConfig, entry.Provider, "", settings)
+		if err != nil {
+			return nil, fmt.Errorf("fallback: %w", err)
+		}
+		links = append(links, llm.ChainLink{Provider: provider, Model: entry.Model})
+	}
+
+	chain := llm.WithFallback(links, func(failed llm.ChainLink, err error, next llm.ChainLink) {
+		fmt.Fprintf(os.Stderr, "%s failed (%s), falling back to %s...\n", failed, failureReason(err), next)
+	})
+	// Missing keys are reported now rather than after the first prompt. A
+	// chain is usable as long as one of its providers is.
+	if err := chain.ValidateCredentials(); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// newRateLimiter returns a limiter for the quotas in appConfig.
+func newRateLimiter(appConfig *config.Config) *llm.RateLimiter {
+	limits := make(map[string]llm.RateLimit, len(appConfig.RateLimits))
+	for key, limit := range appConfig.RateLimits {
+		limits[key] = llm.RateLimit{
+			RequestsPerMinute: limit.RequestsPerMinute,
+			TokensPerMinute:   limit.TokensPerMinute,
+		}
+	}
+	return llm.NewRateLimiter(limits)
+}
+
+// parseFallback parses the --fallback flag, a comma-separated list of
+// provider[:model] entries. Only the first colon splits, since model names
+// such as "qwen2.5-coder:7b" may contain one.
+func parseFallback(spec string) ([]config.FallbackEntry, error) {
+	var entries []config.FallbackEntry
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		provider, model, _ := strings.Cut(part, ":")
+		if provider == "" {
+			return nil, fmt.Errorf("invalid --fallback entry %q: missing provider", part)
+		}
+		entries = append(entries, config.FallbackEntry{Provider: provider, Model: model})
+	}
+	return entries, nil
+}
+
+// failureReason summarises err for a one-line notice.
+func failureReason(err error) string {
+	var statusErr *llm.StatusError
+	if errors.As(err, &statusErr) {
+		return fmt.Sprintf("status %d", statusErr.StatusCode)
+	}
+	return err.Error()
+}
+
+// cassetteFromEnv returns a transport wrapper that records LLM traffic to,
+// or replays it from, the cassette named by DOCKER_AI_CASSETTE.
+// DOCKER_AI_CASSETTE_MODE is "replay" (the default) or "record".
+func cassetteFromEnv() (func(http.RoundTripper) http.RoundTripper, error) {
+	path := os.Getenv("DOCKER_AI_CASSETTE")
+	if path == "" {
+		return nil, nil
+	}
+	mode := cassette.Replay
+	if m := os.Getenv("DOCKER_AI_CASSETTE_MODE"); m != "" {
+		mode = cassette.Mode(m)
+	}
+	c, err := cassette.New(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	return c.Transport, nil
+}
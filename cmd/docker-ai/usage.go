@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"docker-ai/pkg/config"
+	"docker-ai/pkg/usage"
+)
+
+// newUsageTracker returns a tracker priced from appConfig that keeps daily
+// totals in ~/.docker-ai-usage.json.
+func newUsageTracker(appConfig config.Config) *usage.Tracker {
+	path, err := usage.GetUsagePath()
+	if err != nil {
+		fmt.Printf("Warning: daily token totals won't be saved: %v\n", err)
+	}
+	return usage.NewTracker(appConfig.Prices, path)
+}
+
+// runUsageCommand handles `docker-ai usage`, which prints the daily token
+// totals, and returns the exit status.
+func runUsageCommand(args []string) int {
+	if len(args) != 0 {
+		fmt.Println("Usage: docker-ai usage")
+		return 2
+	}
+
+	path, err := usage.GetUsagePath()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	days, err := usage.Load(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if len(days) == 0 {
+		fmt.Println("No token usage recorded yet.")
+		return 0
+	}
+
+	dates := make([]string, 0, len(days))
+	for date := range days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for i, date := range dates {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(date)
+		usage.WriteTable(os.Stdout, days[date])
+	}
+	return 0
+}
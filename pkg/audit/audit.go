@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry records a command that docker-ai generated and who generated it.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Request  string    `json:"request"`
+	Command  string    `json:"command"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model,omitempty"`
+	// Repair numbers the attempts to fix a failed command, starting at 1.
+	// It is zero for the first command generated for a request.
+	Repair int `json:"repair,omitempty"`
+	// Unmentioned lists the command's targets that the user never named,
+	// which may mean the model was steered by Docker's own output.
+	Unmentioned []string `json:"unmentioned,omitempty"`
+}
+
+func GetAuditPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker-ai-audit.log"), nil
+}
+
+// Append adds an entry to the audit log, one JSON object per line.
+func Append(entry Entry) error {
+	auditPath, err := GetAuditPath()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	return json.NewEncoder(f).Encode(entry)
+}
@@ -0,0 +1,233 @@
+// Package cassette records HTTP traffic to a file and replays it offline, so
+// LLM providers can be exercised without network access or API keys.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Mode says whether a cassette talks to the network.
+type Mode string
+
+const (
+	// Record sends requests to the real server and appends each exchange to
+	// the cassette.
+	Record Mode = "record"
+	// Replay answers requests from the cassette and never touches the
+	// network.
+	Replay Mode = "replay"
+)
+
+// scrubbed replaces credentials in recorded headers and URLs.
+const scrubbed = "REDACTED"
+
+// secretNameRegex matches header and query parameter names that carry
+// credentials.
+var secretNameRegex = regexp.MustCompile(`(?i)auth|key|token|secret|cookie|signature`)
+
+// Interaction is one recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded part of an HTTP request.
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is the recorded part of an HTTP response.
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body"`
+}
+
+// Cassette is a file of recorded interactions. Several providers can share
+// one, each through its own Transport.
+type Cassette struct {
+	path string
+	mode Mode
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// New opens the cassette at path. In Record mode, an existing cassette is
+// replaced. In Replay mode, the cassette must exist.
+func New(path string, mode Mode) (*Cassette, error) {
+	c := &Cassette{path: path, mode: mode}
+	switch mode {
+	case Record:
+		return c, nil
+	case Replay:
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("cassette %s does not exist; record it first", path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &c.interactions); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+		}
+		c.used = make([]bool, len(c.interactions))
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q (want %q or %q)", mode, Record, Replay)
+	}
+}
+
+// Transport returns an http.RoundTripper that records the exchanges next
+// makes, or replays them without calling next at all.
+func (c *Cassette) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{cassette: c, next: next}
+}
+
+type transport struct {
+	cassette *Cassette
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := Request{
+		Method:  req.Method,
+		URL:     scrubURL(req.URL),
+		Headers: scrubHeaders(req.Header),
+		Body:    string(body),
+	}
+
+	if t.cassette.mode == Replay {
+		return t.cassette.replay(req, recorded)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.cassette.record(resp, recorded)
+}
+
+// replay returns the first unused interaction whose method, URL and body
+// match the request. Identical requests are answered in recording order.
+func (c *Cassette) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, interaction := range c.interactions {
+		r := interaction.Request
+		if c.used[i] || r.Method != recorded.Method || r.URL != recorded.URL || r.Body != recorded.Body {
+			continue
+		}
+		c.used[i] = true
+		return toHTTPResponse(req, interaction.Response), nil
+	}
+	return nil, fmt.Errorf("cassette %s has no recorded response for %s %s", c.path, recorded.Method, recorded.URL)
+}
+
+// record saves an exchange. The whole response body is read first, so
+// streamed replies are recorded in full.
+func (c *Cassette) record(resp *http.Response, recorded Request) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: scrubHeaders(resp.Header),
+			Body:    string(body),
+		},
+	})
+	// The cassette is rewritten after every exchange so an interrupted run
+	// still leaves a usable file.
+	data, err := json.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(c.path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("could not write cassette: %w", err)
+	}
+	return resp, nil
+}
+
+func toHTTPResponse(req *http.Request, r Response) *http.Response {
+	header := r.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(r.Body))),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// scrubHeaders returns a copy of h with credential headers replaced.
+func scrubHeaders(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	scrubbedHeaders := h.Clone()
+	for name := range scrubbedHeaders {
+		if secretNameRegex.MatchString(name) {
+			scrubbedHeaders[name] = []string{scrubbed}
+		}
+	}
+	return scrubbedHeaders
+}
+
+// scrubURL returns u as a string with credential query parameters and any
+// user info replaced.
+func scrubURL(u *url.URL) string {
+	clean := *u
+	if clean.User != nil {
+		clean.User = url.User(scrubbed)
+	}
+	query := clean.Query()
+	for name := range query {
+		if secretNameRegex.MatchString(name) {
+			query.Set(name, scrubbed)
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
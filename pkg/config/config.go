@@ -4,11 +4,71 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+
+	"docker-ai/pkg/llm"
 )
 
+// ProviderConfig holds settings for a single LLM provider.
+type ProviderConfig struct {
+	BaseURL    string `json:"base_url,omitempty"`
+	AuthHeader string `json:"auth_header,omitempty"`
+	AuthScheme string `json:"auth_scheme,omitempty"`
+	APIKeyEnv  string `json:"api_key_env,omitempty"`
+	Deployment string `json:"deployment,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+	RulesFile  string `json:"rules_file,omitempty"`
+	// Proxy, CAFile, ClientCert and ClientKey are for networks that route
+	// or inspect HTTPS traffic, such as a corporate proxy.
+	Proxy      string `json:"proxy,omitempty"`
+	CAFile     string `json:"ca_file,omitempty"`
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	// Headers are added to every request to the provider.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// FallbackEntry is one provider and model to try when the ones before it
+// fail. An empty Model means the provider's default.
+type FallbackEntry struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+}
+
+// RateLimit is a quota of requests and tokens per minute. Zero means no
+// limit.
+type RateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	TokensPerMinute   int `json:"tokens_per_minute,omitempty"`
+}
+
 type Config struct {
-	SkipCleanupWarning bool   `json:"skip_cleanup_warning"`
-	LastContainerName  string `json:"last_container_name"`
+	SkipCleanupWarning bool                      `json:"skip_cleanup_warning"`
+	LastContainerName  string                    `json:"last_container_name"`
+	Providers          map[string]ProviderConfig `json:"providers,omitempty"`
+	// MaxAttempts is how many times a failed LLM call is tried in total.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Fallback lists the providers to try, in order, when the main one fails.
+	Fallback []FallbackEntry `json:"fallback,omitempty"`
+	// HistoryTokenBudget is roughly how many tokens of conversation history
+	// are kept before older turns are summarised.
+	HistoryTokenBudget int `json:"history_token_budget,omitempty"`
+	// RepairAttempts is how many times a failed command is sent back to the
+	// AI to be fixed. Zero turns repairs off.
+	RepairAttempts int `json:"repair_attempts,omitempty"`
+	// CacheTTL is how long cached replies stay valid, as a duration such as
+	// "12h". CacheMaxEntries caps how many are kept.
+	CacheTTL        string `json:"cache_ttl,omitempty"`
+	CacheMaxEntries int    `json:"cache_max_entries,omitempty"`
+	// Prices maps a model name to its price, overriding the built-in
+	// table used for token cost estimates.
+	Prices map[string]llm.Price `json:"prices,omitempty"`
+	// RedactPatterns are regular expressions for secrets to hide from the
+	// AI, in addition to the built-in detectors.
+	RedactPatterns []string `json:"redact_patterns,omitempty"`
+	// RateLimits maps a provider ("groq") or a provider and model
+	// ("groq/llama-3.1-8b-instant") to its quota. Calls wait rather than
+	// exceed it.
+	RateLimits map[string]RateLimit `json:"rate_limits,omitempty"`
 }
 
 func GetConfigPath() (string, error) {
@@ -57,4 +117,4 @@ func SaveConfig(config Config) error {
 	encoder := json.NewEncoder(f)
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(config)
-} 
\ No newline at end of file
+}
@@ -0,0 +1,252 @@
+// Package inspect looks at the local Docker environment: the context sent
+// with every request, and read-only tools the model can call before it
+// answers.
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"docker-ai/pkg/llm"
+)
+
+// maxOutput caps the characters of tool output sent back to the model.
+const maxOutput = 4000
+
+// maxLogLines caps the tail of container_logs.
+const maxLogLines = 100
+
+// nameRegex matches the container names and IDs Docker accepts.
+var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var nameParameter = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{"type": "string", "description": "Container name or ID"},
+	},
+	"required": []string{"name"},
+}
+
+var noParameters = map[string]interface{}{
+	"type":       "object",
+	"properties": map[string]interface{}{},
+}
+
+// inspectFormat picks the parts of `docker container inspect` worth showing.
+// The environment is left out, since it often holds secrets.
+const inspectFormat = `Name: {{.Name}}
+Image: {{.Config.Image}}
+State: {{json .State}}
+Restart policy: {{.HostConfig.RestartPolicy.Name}}
+Ports: {{json .NetworkSettings.Ports}}
+Mounts: {{json .Mounts}}
+Networks: {{json .NetworkSettings.Networks}}
+Command: {{json .Config.Cmd}}`
+
+// Tools runs read-only docker commands. Commands are run directly, never
+// through a shell, and only with arguments built here. It remembers the
+// images, volumes and networks its output has shown the model; see Seen.
+type Tools struct {
+	mu   sync.Mutex
+	seen []llm.Target
+}
+
+// Definitions lists the tools the model may call.
+func (*Tools) Definitions() []llm.ToolDef {
+	return []llm.ToolDef{
+		{
+			Name:        "inspect_container",
+			Description: "Show the configuration and state of a container: image, state, ports, mounts, networks and restart policy.",
+			Parameters:  nameParameter,
+		},
+		{
+			Name:        "container_logs",
+			Description: "Show the most recent log lines of a container.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string", "description": "Container name or ID"},
+					"tail": map[string]interface{}{"type": "integer", "description": fmt.Sprintf("Number of lines, at most %d", maxLogLines)},
+				},
+				"required": []string{"name"},
+			},
+		},
+		{Name: "list_images", Description: "List local images with their tags and sizes.", Parameters: noParameters},
+		{Name: "list_volumes", Description: "List volumes.", Parameters: noParameters},
+		{Name: "list_networks", Description: "List networks.", Parameters: noParameters},
+		{Name: "system_df", Description: "Show disk usage by images, containers, volumes and build cache.", Parameters: noParameters},
+	}
+}
+
+// Call runs the named tool.
+func (t *Tools) Call(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	text, err := t.call(ctx, name, args)
+	if err == nil {
+		t.mu.Lock()
+		t.seen = append(t.seen, toolTargets(name, text)...)
+		t.mu.Unlock()
+	}
+	return text, err
+}
+
+// Seen returns the objects named in the output of every call so far, so
+// that commands aimed at them can be checked with llm.UnmentionedTargets.
+func (t *Tools) Seen() []llm.Target {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.seen)
+}
+
+func (*Tools) call(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	switch name {
+	case "inspect_container":
+		container, err := nameArg(args)
+		if err != nil {
+			return "", err
+		}
+		return run(ctx, "container", "inspect", "--format", inspectFormat, "--", container)
+	case "container_logs":
+		container, err := nameArg(args)
+		if err != nil {
+			return "", err
+		}
+		return run(ctx, "logs", "--tail", fmt.Sprint(tailArg(args)), "--", container)
+	case "list_images":
+		return run(ctx, "images", "--format", "{{.Repository}}:{{.Tag}} {{.ID}} {{.Size}}")
+	case "list_volumes":
+		return run(ctx, "volume", "ls", "--format", "{{.Name}} {{.Driver}}")
+	case "list_networks":
+		return run(ctx, "network", "ls", "--format", "{{.Name}} {{.Driver}} {{.Scope}}")
+	case "system_df":
+		return run(ctx, "system", "df")
+	default:
+		return "", fmt.Errorf("unknown tool %q", name)
+	}
+}
+
+// toolTargets returns the objects listed in the output of a list_* tool.
+// Images go by repository, repository:tag and ID; volumes and networks by
+// name.
+func toolTargets(tool, text string) []llm.Target {
+	var targets []llm.Target
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "..." {
+			continue
+		}
+		switch tool {
+		case "list_images":
+			if len(fields) < 2 {
+				continue
+			}
+			target := llm.Target{ID: strings.TrimPrefix(fields[1], "sha256:")}
+			if repo := fields[0][:max(strings.LastIndex(fields[0], ":"), 0)]; repo != "" && repo != "<none>" {
+				target.Names = []string{fields[0], repo}
+			}
+			targets = append(targets, target)
+		case "list_volumes", "list_networks":
+			targets = append(targets, llm.Target{Names: fields[:1]})
+		}
+	}
+	return targets
+}
+
+// Describe renders a call for a one-line notice, e.g. "container_logs web".
+func Describe(call llm.ToolCall) string {
+	if name, ok := call.Args["name"].(string); ok {
+		return call.Name + " " + name
+	}
+	return call.Name
+}
+
+func nameArg(args map[string]interface{}) (string, error) {
+	name, _ := args["name"].(string)
+	if !nameRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	return name, nil
+}
+
+// tailArg returns the requested number of log lines, defaulting to 20.
+// JSON numbers decode as float64.
+func tailArg(args map[string]interface{}) int {
+	tail := 20
+	if n, ok := args["tail"].(float64); ok && n > 0 {
+		tail = int(n)
+	}
+	if tail > maxLogLines {
+		tail = maxLogLines
+	}
+	return tail
+}
+
+// run runs docker with args and returns its output, truncated for the model.
+func run(ctx context.Context, args ...string) (string, error) {
+	text, err := output(ctx, args...)
+	if len(text) > maxOutput {
+		text = text[:maxOutput] + "\n... (truncated)"
+	}
+	return text, err
+}
+
+// output runs docker with args and returns its trimmed output. On failure
+// the output becomes part of the error.
+func output(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	text := strings.TrimSpace(string(out))
+	if err != nil {
+		if text == "" {
+			return "", err
+		}
+		return "", fmt.Errorf("%v: %s", err, text)
+	}
+	return text, nil
+}
+
+// Containers lists every container, running or stopped, as "name (status)".
+func Containers(ctx context.Context) ([]string, error) {
+	text, err := output(ctx, "ps", "-a", "--format", "{{.Names}} ({{.Status}})")
+	if err != nil || text == "" {
+		return nil, err
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+// ContainerTargets lists every container by its names and full ID, for
+// llm.UnmentionedTargets.
+func ContainerTargets(ctx context.Context) []llm.Target {
+	text, err := output(ctx, "ps", "-a", "--no-trunc", "--format", "{{.ID}} {{.Names}}")
+	if err != nil || text == "" {
+		return nil
+	}
+	var targets []llm.Target
+	for _, line := range strings.Split(text, "\n") {
+		id, names, _ := strings.Cut(line, " ")
+		targets = append(targets, llm.Target{Names: strings.Split(names, ","), ID: id})
+	}
+	return targets
+}
+
+// Version returns the Docker daemon's version, or the client's if the daemon
+// can't be reached, or "" if neither can be found.
+func Version(ctx context.Context) string {
+	if version, err := output(ctx, "version", "--format", "{{.Server.Version}}"); err == nil && version != "" {
+		return version
+	}
+	version, _ := output(ctx, "version", "--format", "{{.Client.Version}}")
+	return version
+}
+
+// Plugins lists the installed CLI plugins, or nil if they can't be listed.
+func Plugins(ctx context.Context) []string {
+	text, err := output(ctx, "info", "--format", "{{range .ClientInfo.Plugins}}{{.Name}} {{end}}")
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(text)
+}
@@ -0,0 +1,42 @@
+package inspect
+
+import (
+	"reflect"
+	"testing"
+
+	"docker-ai/pkg/llm"
+)
+
+func TestToolTargets(t *testing.T) {
+	tests := []struct {
+		tool string
+		text string
+		want []llm.Target
+	}{
+		{
+			tool: "list_images",
+			text: "nginx:latest a1b2c3d4e5f6 188MB\nregistry:5000/app:1.2 sha256:0f1e2d3c4b5a 50MB\n<none>:<none> 99aa88bb77cc 1GB",
+			want: []llm.Target{
+				{Names: []string{"nginx:latest", "nginx"}, ID: "a1b2c3d4e5f6"},
+				{Names: []string{"registry:5000/app:1.2", "registry:5000/app"}, ID: "0f1e2d3c4b5a"},
+				{ID: "99aa88bb77cc"},
+			},
+		},
+		{
+			tool: "list_volumes",
+			text: "pgdata local\ncache local\n... (truncated)",
+			want: []llm.Target{{Names: []string{"pgdata"}}, {Names: []string{"cache"}}},
+		},
+		{
+			tool: "list_networks",
+			text: "bridge bridge local\nbackend bridge local",
+			want: []llm.Target{{Names: []string{"bridge"}}, {Names: []string{"backend"}}},
+		},
+		{tool: "system_df", text: "TYPE TOTAL ACTIVE SIZE RECLAIMABLE"},
+	}
+	for _, tt := range tests {
+		if got := toolTargets(tt.tool, tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("toolTargets(%s) = %+v, want %+v", tt.tool, got, tt.want)
+		}
+	}
+}
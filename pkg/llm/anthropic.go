@@ -0,0 +1,233 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	defaultAnthropicBaseURL = "https://api.anthropic.com/v1"
+	anthropicVersion        = "2023-06-01"
+)
+
+// anthropicResponse is the Messages API reply. Content is a list of blocks;
+// only "text" blocks carry the answer.
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
+}
+
+// anthropicUsage is the token count of a message. In a stream, input tokens
+// come with message_start and output tokens with message_delta.
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicError is the body the Messages API returns on failure.
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropic talks to the Anthropic Messages API.
+type anthropic struct {
+	baseURL   string
+	apiKeyEnv string
+	client    *http.Client
+}
+
+func init() {
+	Register("anthropic", func(opts Options) Provider {
+		p := &anthropic{
+			baseURL:   defaultAnthropicBaseURL,
+			apiKeyEnv: "ANTHROPIC_API_KEY",
+			client:    newHTTPClient(opts),
+		}
+		if opts.BaseURL != "" {
+			p.baseURL = strings.TrimRight(opts.BaseURL, "/")
+		}
+		if opts.APIKeyEnv != "" {
+			p.apiKeyEnv = opts.APIKeyEnv
+		}
+		return p
+	})
+}
+
+func (p *anthropic) Name() string { return "anthropic" }
+
+func (p *anthropic) Capabilities() Capabilities {
+	return Capabilities{Streaming: true}
+}
+
+func (p *anthropic) ValidateCredentials() error {
+	if os.Getenv(p.apiKeyEnv) == "" {
+		return fmt.Errorf("%s not set", p.apiKeyEnv)
+	}
+	return nil
+}
+
+func (p *anthropic) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := p.post(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var anthropicResp anthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	for _, block := range anthropicResp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return nil, errors.New("anthropic returned no text content")
+	}
+
+	return &Response{
+		Content: text.String(),
+		Usage: &Usage{
+			Model:            anthropicResp.Model,
+			PromptTokens:     anthropicResp.Usage.InputTokens,
+			CompletionTokens: anthropicResp.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+func (p *anthropic) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	resp, err := p.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var text strings.Builder
+	usage := &Usage{}
+	err = readSSE(resp.Body, func(event, data string) error {
+		switch event {
+		case "message_start":
+			var start struct {
+				Message anthropicResponse `json:"message"`
+			}
+			if err := json.Unmarshal([]byte(data), &start); err != nil {
+				return fmt.Errorf("malformed stream event: %w", err)
+			}
+			usage.Model = start.Message.Model
+			usage.PromptTokens = start.Message.Usage.InputTokens
+		case "message_delta":
+			var delta struct {
+				Usage anthropicUsage `json:"usage"`
+			}
+			if err := json.Unmarshal([]byte(data), &delta); err != nil {
+				return fmt.Errorf("malformed stream event: %w", err)
+			}
+			usage.CompletionTokens = delta.Usage.OutputTokens
+		case "content_block_delta":
+			var delta struct {
+				Delta struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"delta"`
+			}
+			if err := json.Unmarshal([]byte(data), &delta); err != nil {
+				return fmt.Errorf("malformed stream event: %w", err)
+			}
+			if delta.Delta.Type == "text_delta" && delta.Delta.Text != "" {
+				text.WriteString(delta.Delta.Text)
+				onChunk(delta.Delta.Text)
+			}
+		case "error":
+			var apiErr anthropicError
+			if err := json.Unmarshal([]byte(data), &apiErr); err != nil {
+				return fmt.Errorf("malformed stream event: %w", err)
+			}
+			return fmt.Errorf("anthropic stream failed (%s): %s", apiErr.Error.Type, apiErr.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if text.Len() == 0 {
+		return nil, errors.New("anthropic returned no text content")
+	}
+
+	return &Response{Content: text.String(), Usage: usage}, nil
+}
+
+// post sends a Messages API request and returns the response once its
+// status has been checked. The caller must close the body.
+func (p *anthropic) post(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	if err := p.ValidateCredentials(); err != nil {
+		return nil, err
+	}
+
+	model := req.Model
+	if model == "" {
+		model = DefaultModel("anthropic")
+	}
+
+	payload := map[string]interface{}{
+		"model":       model,
+		"system":      req.SystemPrompt,
+		"messages":    chatMessages(req, false),
+		"max_tokens":  1024,
+		"temperature": 0.1,
+		"stream":      stream,
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/messages", bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("x-api-key", os.Getenv(p.apiKeyEnv))
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		var apiErr anthropicError
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, newStatusError(resp, fmt.Errorf("anthropic request failed with status %d (%s): %s", resp.StatusCode, apiErr.Error.Type, apiErr.Error.Message))
+		}
+		return nil, newStatusError(resp, fmt.Errorf("LLM API request failed with status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+const defaultAzureAPIVersion = "2024-10-21"
+
+// azureOpenAI speaks the OpenAI chat-completions protocol, but addresses a
+// deployment on the user's own resource and authenticates with an api-key
+// header instead of a bearer token.
+type azureOpenAI struct {
+	*openAICompatible
+	deployment string
+	apiVersion string
+}
+
+func init() {
+	Register("azure-openai", func(opts Options) Provider {
+		if opts.BaseURL == "" {
+			opts.BaseURL = os.Getenv("AZURE_OPENAI_ENDPOINT")
+		}
+		if opts.AuthHeader == "" {
+			opts.AuthHeader = "api-key"
+			opts.AuthScheme = "none"
+		}
+		p := &azureOpenAI{
+			openAICompatible: newOpenAICompatible(opts, openAICompatible{
+				name:        "azure-openai",
+				apiKeyEnv:   "AZURE_OPENAI_API_KEY",
+				jsonMode:    true,
+				toolCalling: true,
+				streamUsage: true,
+			}),
+			deployment: opts.Deployment,
+			apiVersion: opts.APIVersion,
+		}
+		if p.deployment == "" {
+			p.deployment = os.Getenv("AZURE_OPENAI_DEPLOYMENT")
+		}
+		if p.apiVersion == "" {
+			p.apiVersion = os.Getenv("OPENAI_API_VERSION")
+		}
+		if p.apiVersion == "" {
+			p.apiVersion = defaultAzureAPIVersion
+		}
+		return p
+	})
+}
+
+func (p *azureOpenAI) ValidateCredentials() error {
+	if p.baseURL == "" {
+		return errors.New("azure-openai requires a resource endpoint (set AZURE_OPENAI_ENDPOINT or --llm-base-url)")
+	}
+	return p.openAICompatible.ValidateCredentials()
+}
+
+func (p *azureOpenAI) Complete(ctx context.Context, req Request) (*Response, error) {
+	endpoint, err := p.endpoint(&req)
+	if err != nil {
+		return nil, err
+	}
+	return p.complete(ctx, req, endpoint)
+}
+
+func (p *azureOpenAI) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	endpoint, err := p.endpoint(&req)
+	if err != nil {
+		return nil, err
+	}
+	return p.stream(ctx, req, endpoint, onChunk)
+}
+
+// endpoint validates the settings and builds the deployment URL, pointing
+// req.Model at the deployment.
+func (p *azureOpenAI) endpoint(req *Request) (string, error) {
+	if err := p.ValidateCredentials(); err != nil {
+		return "", err
+	}
+
+	// Azure routes on the deployment name; --model is only a fallback for
+	// users who named their deployment after the model.
+	deployment := p.deployment
+	if deployment == "" {
+		deployment = req.Model
+	}
+	if deployment == "" {
+		return "", errors.New("azure-openai requires a deployment (set AZURE_OPENAI_DEPLOYMENT, the \"deployment\" config setting, or --model)")
+	}
+
+	req.Model = deployment
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		p.baseURL, url.PathEscape(deployment), url.QueryEscape(p.apiVersion)), nil
+}
@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache defaults.
+const (
+	DefaultCacheTTL        = 24 * time.Hour
+	DefaultCacheMaxEntries = 500
+)
+
+// Cache stores replies on disk, one JSON file per request, so repeated
+// requests don't cost an API round trip.
+type Cache struct {
+	Dir string
+	// TTL is how long a reply stays valid.
+	TTL time.Duration
+	// MaxEntries caps the number of stored replies; the oldest are removed
+	// first.
+	MaxEntries int
+}
+
+// cacheEntry is the file format of a cached reply.
+type cacheEntry struct {
+	Created  time.Time `json:"created"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model,omitempty"`
+	Reply    *Reply    `json:"reply"`
+}
+
+// DefaultCacheDir returns ~/.docker-ai-cache.
+func DefaultCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker-ai-cache"), nil
+}
+
+// NewCache returns a cache in dir, using the defaults for a zero ttl or
+// maxEntries.
+func NewCache(dir string, ttl time.Duration, maxEntries int) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	return &Cache{Dir: dir, TTL: ttl, MaxEntries: maxEntries}
+}
+
+// uptimeRegex matches the durations in `docker ps` statuses, such as
+// "Up 5 minutes" or "Exited (0) About an hour ago".
+var uptimeRegex = regexp.MustCompile(`\b(Up|Exited \(-?\d+\)|Restarting \(-?\d+\)) (Less than |About )?(an?|\d+) (second|minute|hour|day|week|month|year)s?( ago)?`)
+
+// normalizePrompt strips what changes between otherwise identical requests:
+// container uptimes and runs of whitespace.
+func normalizePrompt(prompt string) string {
+	prompt = uptimeRegex.ReplaceAllString(prompt, "$1")
+	return strings.Join(strings.Fields(prompt), " ")
+}
+
+// cacheKey hashes everything that can change the reply: the provider and
+// model, the system prompt with its container context (so editing either
+// invalidates old replies), the history, and the prompt. The prompts are
+// normalised first.
+func cacheKey(provider string, req Request) string {
+	h := sha256.New()
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	write(provider)
+	write(req.Model)
+	write(normalizePrompt(req.SystemPrompt))
+	for _, m := range req.History {
+		write(m.Role)
+		write(m.Content)
+	}
+	write(normalizePrompt(req.Prompt))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// get returns the cached entry for key, or nil if there is none or it has
+// expired. A nil Cache never has entries.
+func (c *Cache) get(key string) *cacheEntry {
+	if c == nil {
+		return nil
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Reply == nil || time.Since(entry.Created) > c.TTL {
+		os.Remove(c.path(key))
+		return nil
+	}
+	return &entry
+}
+
+// put stores an entry and trims the cache to MaxEntries. Failures are
+// ignored, since the cache is only an optimisation.
+func (c *Cache) put(key string, entry cacheEntry) {
+	if c == nil {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(c.path(key), data, 0o600); err != nil {
+		return
+	}
+	c.trim()
+}
+
+// trim removes the oldest entries beyond MaxEntries.
+func (c *Cache) trim() {
+	files, err := filepath.Glob(filepath.Join(c.Dir, "*.json"))
+	if err != nil || len(files) <= c.MaxEntries {
+		return
+	}
+	modTimes := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			modTimes[f] = info.ModTime()
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return modTimes[files[i]].Before(modTimes[files[j]]) })
+	for _, f := range files[:len(files)-c.MaxEntries] {
+		os.Remove(f)
+	}
+}
+
+// Forget removes the entry for key, e.g. when its command failed.
+func (c *Cache) Forget(key string) {
+	if c == nil || key == "" {
+		return
+	}
+	os.Remove(c.path(key))
+}
+
+// Clear removes every cached reply and returns how many there were.
+func (c *Cache) Clear() (int, error) {
+	files, err := filepath.Glob(filepath.Join(c.Dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(files) - len(errs), errors.Join(errs...)
+}
@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"docker-ai/pkg/cassette"
+
+	"google.golang.org/genai"
+)
+
+// The cassettes in testdata/synthetic were not recorded: they follow the
+// format of each provider's API reference, with made-up IDs, timestamps and
+// token counts. They pin down how each provider builds its requests and parses
+// complete, streamed and error replies, but not that the real API still
+// answers that way. To check that, record real cassettes into
+// testdata/recorded, using the API keys in the environment:
+//
+//	go test ./pkg/llm -run TestProviderCassettes -record
+//
+// A recorded cassette is replayed instead of the synthetic one when it
+// exists. Azure and openai-compatible are recorded against the BaseURL in
+// cassetteProviders, so point it at your own endpoint first.
+var recordCassettes = flag.Bool("record", false, "record the provider cassettes in testdata/recorded against the real APIs")
+
+// cassettePath returns the cassette to record or replay for provider.
+func cassettePath(provider string) string {
+	recorded := filepath.Join("testdata", "recorded", provider+".json")
+	if _, err := os.Stat(recorded); *recordCassettes || err == nil {
+		return recorded
+	}
+	return filepath.Join("testdata", "synthetic", provider+".json")
+}
+
+// cassetteProviders lists each provider with the options and model its
+// cassette was recorded with, and the variable that holds its key.
+// noStreamUsage is set for providers that don't report token counts when
+// streaming.
+var cassetteProviders = []struct {
+	name          string
+	opts          Options
+	model         string
+	keyEnv        string
+	noStreamUsage bool
+}{
+	{name: "groq", model: "llama-3.3-70b-versatile", keyEnv: "GROQ_API_KEY"},
+	{name: "openai", model: "gpt-4o-mini", keyEnv: "OPENAI_API_KEY"},
+	{name: "openai-compatible", opts: Options{BaseURL: "http://localhost:8000/v1"}, model: "qwen2.5-7b-instruct", noStreamUsage: true},
+	{name: "azure-openai", opts: Options{BaseURL: "https://docker-ai-test.openai.azure.com"}, model: "gpt-4o-mini", keyEnv: "AZURE_OPENAI_API_KEY"},
+	{name: "anthropic", model: "claude-haiku-4-5", keyEnv: "ANTHROPIC_API_KEY"},
+	{name: "gemini", model: "gemini-2.5-flash", keyEnv: "GEMINI_API_KEY"},
+	{name: "ollama", opts: Options{BaseURL: "http://localhost:11434"}, model: "llama3.2"},
+}
+
+const (
+	jsonSystemPrompt   = `You are a Docker assistant. Answer with a JSON object with the keys "explanation", "risk_level" and "command".`
+	fencedSystemPrompt = "You are a Docker assistant. Answer with the command in a bash code block."
+)
+
+// cassetteCases are the requests recorded for every provider: a JSON reply,
+// a reply in a markdown code block, a streamed JSON reply, and a request for
+// a model that doesn't exist, which every provider answers with a 404.
+var cassetteCases = []struct {
+	name        string
+	req         Request
+	stream      bool
+	wantCommand string
+	wantStatus  int
+}{
+	{
+		name:        "json",
+		req:         Request{SystemPrompt: jsonSystemPrompt, Prompt: "list running containers", JSON: true},
+		wantCommand: "docker ps",
+	},
+	{
+		name:        "fenced",
+		req:         Request{SystemPrompt: fencedSystemPrompt, Prompt: "list images"},
+		wantCommand: "docker images",
+	},
+	{
+		name:        "stream",
+		req:         Request{SystemPrompt: jsonSystemPrompt, Prompt: "list stopped containers", JSON: true},
+		stream:      true,
+		wantCommand: "docker ps -a --filter status=exited",
+	},
+	{
+		name:       "error status",
+		req:        Request{SystemPrompt: jsonSystemPrompt, Prompt: "list volumes", Model: "no-such-model"},
+		wantStatus: 404,
+	},
+}
+
+func TestProviderCassettes(t *testing.T) {
+	for _, p := range cassetteProviders {
+		t.Run(p.name, func(t *testing.T) {
+			path := cassettePath(p.name)
+			mode := cassette.Replay
+			if *recordCassettes {
+				mode = cassette.Record
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			} else if p.keyEnv != "" {
+				t.Setenv(p.keyEnv, "test-key")
+			}
+			c, err := cassette.New(path, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := p.opts
+			opts.WrapTransport = c.Transport
+			provider, err := NewProvider(p.name, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, tc := range cassetteCases {
+				t.Run(tc.name, func(t *testing.T) {
+					req := tc.req
+					if req.Model == "" {
+						req.Model = p.model
+					}
+					var chunks []string
+					var resp *Response
+					var err error
+					if tc.stream {
+						resp, err = provider.(Streamer).Stream(context.Background(), req, func(chunk string) {
+							chunks = append(chunks, chunk)
+						})
+					} else {
+						resp, err = provider.Complete(context.Background(), req)
+					}
+					if tc.wantStatus != 0 {
+						if got := errorStatus(err); got != tc.wantStatus {
+							t.Fatalf("error = %v (status %d), want status %d", err, got, tc.wantStatus)
+						}
+						return
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					reply, err := ParseReply(resp.Content)
+					if err != nil {
+						t.Fatalf("ParseReply(%q): %v", resp.Content, err)
+					}
+					if reply.Command != tc.wantCommand {
+						t.Errorf("Command = %q, want %q (reply %q)", reply.Command, tc.wantCommand, resp.Content)
+					}
+					if tc.stream {
+						if len(chunks) < 2 || strings.Join(chunks, "") != resp.Content {
+							t.Errorf("streamed %q, want the reply %q in several chunks", chunks, resp.Content)
+						}
+						if p.noStreamUsage {
+							return
+						}
+					}
+					if resp.Usage == nil || resp.Usage.PromptTokens == 0 {
+						t.Errorf("Usage = %+v, want the recorded token counts", resp.Usage)
+					}
+				})
+			}
+		})
+	}
+}
+
+// errorStatus returns the HTTP status of a provider error, or 0.
+func errorStatus(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package llm
+
+// Price is what a model costs in US dollars per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// ModelInfo is what docker-ai knows about one model of one provider.
+type ModelInfo struct {
+	Provider string
+	Name     string
+	// Default marks the model a provider uses when none is given.
+	Default bool
+	// ContextWindow is the most tokens the model accepts, prompt and reply
+	// together.
+	ContextWindow int
+	// JSONMode and ToolCalling are set if the model supports them through
+	// this provider.
+	JSONMode    bool
+	ToolCalling bool
+	// Price is nil if it isn't known. Local models are free.
+	Price *Price
+}
+
+// Catalog lists the models docker-ai knows about. Other models can still be
+// used; docker-ai just can't tell what they support. Prices change, so check
+// your provider's pricing page if the numbers matter.
+var Catalog = []ModelInfo{
+	{Provider: "groq", Name: "llama-3.3-70b-versatile", Default: true, ContextWindow: 131072, JSONMode: true, ToolCalling: true, Price: &Price{Input: 0.59, Output: 0.79}},
+	{Provider: "groq", Name: "llama-3.1-8b-instant", ContextWindow: 131072, JSONMode: true, ToolCalling: true, Price: &Price{Input: 0.05, Output: 0.08}},
+
+	{Provider: "gemini", Name: "gemini-2.5-flash", Default: true, ContextWindow: 1048576, JSONMode: true, ToolCalling: true, Price: &Price{Input: 0.30, Output: 2.50}},
+	{Provider: "gemini", Name: "gemini-2.5-flash-lite", ContextWindow: 1048576, JSONMode: true, ToolCalling: true, Price: &Price{Input: 0.10, Output: 0.40}},
+	{Provider: "gemini", Name: "gemini-2.5-pro", ContextWindow: 1048576, JSONMode: true, ToolCalling: true, Price: &Price{Input: 1.25, Output: 10.00}},
+
+	{Provider: "openai", Name: "gpt-4o", Default: true, ContextWindow: 128000, JSONMode: true, ToolCalling: true, Price: &Price{Input: 2.50, Output: 10.00}},
+	{Provider: "openai", Name: "gpt-4o-mini", ContextWindow: 128000, JSONMode: true, ToolCalling: true, Price: &Price{Input: 0.15, Output: 0.60}},
+
+	{Provider: "anthropic", Name: "claude-sonnet-4-5", Default: true, ContextWindow: 200000, Price: &Price{Input: 3.00, Output: 15.00}},
+	{Provider: "anthropic", Name: "claude-haiku-4-5", ContextWindow: 200000, Price: &Price{Input: 1.00, Output: 5.00}},
+
+	{Provider: "ollama", Name: "llama3.2", Default: true, ContextWindow: 131072, JSONMode: true, Price: &Price{}},
+	{Provider: "ollama", Name: "qwen2.5-coder:7b", ContextWindow: 32768, JSONMode: true, Price: &Price{}},
+}
+
+// DefaultModel returns the model provider uses when none is given, or ""
+// if it has none, as with azure-openai, whose deployment names the model.
+func DefaultModel(provider string) string {
+	for _, m := range Catalog {
+		if m.Provider == provider && m.Default {
+			return m.Name
+		}
+	}
+	return ""
+}
+
+// LookupModel returns the catalog entry for a provider's model.
+func LookupModel(provider, model string) (ModelInfo, bool) {
+	for _, m := range Catalog {
+		if m.Provider == provider && m.Name == model {
+			return m, true
+		}
+	}
+	return ModelInfo{}, false
+}
+
+// Models returns the catalog entries for provider, or for every provider
+// if it is empty.
+func Models(provider string) []ModelInfo {
+	var models []ModelInfo
+	for _, m := range Catalog {
+		if provider == "" || m.Provider == provider {
+			models = append(models, m)
+		}
+	}
+	return models
+}
@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Message roles used in a conversation history.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is one message of a conversation: an earlier turn sent ahead of
+// the current prompt, or a tool call and its result sent after it.
+type Message struct {
+	Role    string
+	Content string
+	// ToolCalls are the calls an assistant message asked for.
+	ToolCalls []ToolCall
+	// ToolCallID and Name identify the call a tool message answers.
+	ToolCallID string
+	Name       string
+}
+
+// Turn is one request in an interactive session and what came of it.
+type Turn struct {
+	Request string
+	// Command is the command that was generated, if any.
+	Command string
+	// Answer is the question or explanation given instead of a command.
+	Answer string
+	// ExitCode is the command's exit status, or -1 if it was not run.
+	ExitCode int
+}
+
+// DefaultHistoryBudget is the approximate number of tokens of history sent
+// with each request before older turns are summarised.
+const DefaultHistoryBudget = 2000
+
+const summaryPrompt = `You maintain the memory of a Docker CLI assistant. Summarise the conversation below in a few short sentences. Keep every container, image, volume and network name that was mentioned, which commands were run, and whether they succeeded. Output only the summary.`
+
+// Conversation keeps the history of an interactive session so that
+// follow-up requests like "now do the same for redis" have context.
+type Conversation struct {
+	mu      sync.Mutex
+	summary string
+	turns   []Turn
+	// Budget is the approximate token limit for the history; see
+	// DefaultHistoryBudget.
+	Budget int
+}
+
+// NewConversation returns an empty conversation with the given token budget.
+func NewConversation(budget int) *Conversation {
+	if budget <= 0 {
+		budget = DefaultHistoryBudget
+	}
+	return &Conversation{Budget: budget}
+}
+
+// Add records a finished turn. It does nothing on a nil Conversation, so
+// single-command mode can share the same code path.
+func (c *Conversation) Add(turn Turn) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.turns = append(c.turns, turn)
+}
+
+// Reset forgets the whole conversation.
+func (c *Conversation) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summary = ""
+	c.turns = nil
+}
+
+// Mentioned returns the user's requests, so that callers can tell which
+// names came from the user. The commands that were run are left out: the
+// model wrote them, so a name in them may have come from Docker. Older
+// turns that have been summarised are no longer included.
+func (c *Conversation) Mentioned() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var b strings.Builder
+	for _, turn := range c.turns {
+		b.WriteString(turn.Request + "\n")
+	}
+	return b.String()
+}
+
+// Messages returns the history as alternating user and assistant messages,
+// starting with the summary of older turns if there is one.
+func (c *Conversation) Messages() []Message {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.messages()
+}
+
+func (c *Conversation) messages() []Message {
+	var messages []Message
+	if c.summary != "" {
+		messages = append(messages,
+			Message{Role: RoleUser, Content: "Summary of our conversation so far: " + c.summary},
+			Message{Role: RoleAssistant, Content: "Understood."},
+		)
+	}
+	for _, turn := range c.turns {
+		messages = append(messages,
+			Message{Role: RoleUser, Content: turn.Request},
+			Message{Role: RoleAssistant, Content: turn.describe()},
+		)
+	}
+	return messages
+}
+
+// describe renders the assistant's side of a turn.
+func (t Turn) describe() string {
+	switch {
+	case t.Command == "":
+		return t.Answer
+	case t.ExitCode < 0:
+		return fmt.Sprintf("Suggested `%s` (not run).", t.Command)
+	default:
+		return fmt.Sprintf("Ran `%s` (exit status %d).", t.Command, t.ExitCode)
+	}
+}
+
+// Compact summarises the older half of the history with provider whenever
+// the history is over budget, so the most recent turns are kept verbatim.
+// If summarising fails, the older turns are dropped instead.
+func (c *Conversation) Compact(ctx context.Context, provider Provider, model string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if estimateTokens(c.messages()) <= c.Budget || len(c.turns) < 2 {
+		return nil
+	}
+
+	older := c.turns[:len(c.turns)/2]
+	var transcript strings.Builder
+	if c.summary != "" {
+		fmt.Fprintf(&transcript, "Earlier summary: %s\n\n", c.summary)
+	}
+	for _, turn := range older {
+		fmt.Fprintf(&transcript, "User: %s\nAssistant: %s\n", turn.Request, turn.describe())
+	}
+
+	resp, err := provider.Complete(ctx, Request{
+		Model:        model,
+		SystemPrompt: summaryPrompt,
+		Prompt:       transcript.String(),
+	})
+	c.turns = append([]Turn(nil), c.turns[len(older):]...)
+	if err != nil {
+		return fmt.Errorf("could not summarise conversation history: %w", err)
+	}
+	c.summary = strings.TrimSpace(resp.Content)
+	return nil
+}
+
+// estimateTokens approximates the token count of messages at four
+// characters per token, which is close enough to decide when to compact.
+func estimateTokens(messages []Message) int {
+	chars := 0
+	for _, m := range messages {
+		chars += len(m.Content)
+	}
+	return chars / 4
+}
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// fenceRegex matches the opening or closing line of a markdown code block.
+var fenceRegex = regexp.MustCompile("^\\s*(```|~~~)")
+
+// listMarkerRegex matches a bullet or number at the start of a list item.
+var listMarkerRegex = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+
+// promptRegex matches a shell prompt in front of a command, such as "$ ",
+// "# " or "user@host:~/app$ ".
+var promptRegex = regexp.MustCompile(`^(?:[\w.@~:/-]*[$#>%]\s*)+`)
+
+// inlineCodeRegex matches a markdown code span.
+var inlineCodeRegex = regexp.MustCompile("`([^`\n]+)`")
+
+// ExtractDockerCommands finds the docker commands in a model's free-text
+// answer. Each result is one runnable command. Commands that are meant to
+// run together, such as the lines of one code block or consecutive lines of
+// a list, are joined with " && "; commands separated by prose, such as two
+// code blocks with "or" between them, are returned separately, since they
+// are usually alternatives.
+//
+// Code blocks win: if there are any with docker commands, commands in the
+// surrounding prose are ignored. Shell prompts ("$ "), list markers,
+// "sudo", and backslash continuations are removed.
+func ExtractDockerCommands(text string) []string {
+	var fenced, loose []string
+	var block, run []string
+	inFence := false
+
+	flush := func(group *[]string, into *[]string) {
+		if len(*group) > 0 {
+			*into = append(*into, strings.Join(*group, " && "))
+			*group = nil
+		}
+	}
+
+	for _, line := range joinContinuations(strings.Split(text, "\n")) {
+		if fenceRegex.MatchString(line) {
+			if inFence {
+				flush(&block, &fenced)
+			} else {
+				flush(&run, &loose)
+			}
+			inFence = !inFence
+			continue
+		}
+
+		if inFence {
+			if command, ok := commandLine(line); ok {
+				block = append(block, command)
+			}
+			continue
+		}
+
+		line = listMarkerRegex.ReplaceAllString(line, "")
+		if m := inlineCodeRegex.FindStringSubmatch(line); m != nil && strings.TrimSpace(line) == m[0] {
+			// A line that is only a code span, as in "1. `docker ps`".
+			line = m[1]
+		}
+		if command, ok := commandLine(line); ok {
+			run = append(run, command)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// Prose ends a run of commands. Code spans in it are each a
+		// separate suggestion.
+		flush(&run, &loose)
+		for _, m := range inlineCodeRegex.FindAllStringSubmatch(line, -1) {
+			if command, ok := commandLine(m[1]); ok {
+				loose = append(loose, command)
+			}
+		}
+	}
+	// An unclosed block still counts: models often stop before the fence.
+	flush(&block, &fenced)
+	flush(&run, &loose)
+
+	if len(fenced) > 0 {
+		return fenced
+	}
+	return loose
+}
+
+// commandLine strips a prompt and "sudo" from line and reports whether what
+// is left is a docker command.
+func commandLine(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if loc := promptRegex.FindStringIndex(line); loc != nil {
+		if command := strings.TrimPrefix(strings.TrimSpace(line[loc[1]:]), "sudo "); isDockerCommand(command) {
+			return command, true
+		}
+	}
+	line = strings.TrimPrefix(line, "sudo ")
+	return line, isDockerCommand(line)
+}
+
+// joinContinuations joins lines that end with a backslash to the line after
+// them, as the shell would. Commands that end with "&&", "||" or "|" are
+// continued the same way. A code fence always starts a new line.
+func joinContinuations(lines []string) []string {
+	var joined []string
+	pending := ""
+	for _, line := range lines {
+		if pending != "" {
+			if fenceRegex.MatchString(line) {
+				joined = append(joined, pending)
+			} else {
+				line = pending + " " + strings.TrimSpace(line)
+			}
+			pending = ""
+		}
+		line = strings.TrimRight(line, " \t\r")
+		switch {
+		case strings.HasSuffix(line, "\\"):
+			pending = strings.TrimRight(strings.TrimSuffix(line, "\\"), " \t")
+		case endsWithOperator(line):
+			pending = line
+		default:
+			joined = append(joined, line)
+		}
+	}
+	if pending != "" {
+		joined = append(joined, pending)
+	}
+	return joined
+}
+
+// endsWithOperator reports whether line is a command that ends with a
+// pipe or a list operator, so it continues on the next line. Prose lines,
+// such as markdown table rows, don't.
+func endsWithOperator(line string) bool {
+	if _, ok := commandLine(listMarkerRegex.ReplaceAllString(line, "")); !ok {
+		return false
+	}
+	return strings.HasSuffix(line, "&&") || strings.HasSuffix(line, "||") || strings.HasSuffix(line, "|")
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractDockerCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "prefaced code block",
+			text: "Sure! To list the running containers, run:\n\n```bash\ndocker ps\n```\n\nThis shows their names and status.",
+			want: []string{"docker ps"},
+		},
+		{
+			name: "prompt",
+			text: "Run this:\n\n$ docker ps -a\n",
+			want: []string{"docker ps -a"},
+		},
+		{
+			name: "prompt with user and directory",
+			text: "```\nuser@host:~/app$ docker compose up -d\n```",
+			want: []string{"docker compose up -d"},
+		},
+		{
+			name: "sudo",
+			text: "```\n$ sudo docker ps\n```",
+			want: []string{"docker ps"},
+		},
+		{
+			name: "backslash continuation",
+			text: "```sh\ndocker run -d \\\n  --name web \\\n  -p 80:80 nginx\n```",
+			want: []string{"docker run -d --name web -p 80:80 nginx"},
+		},
+		{
+			name: "pipe continuation",
+			text: "```\ndocker ps -a |\n  grep web\n```",
+			want: []string{"docker ps -a | grep web"},
+		},
+		{
+			name: "lines of one block",
+			text: "```bash\n# stop it first\ndocker stop web\ndocker rm web\n```",
+			want: []string{"docker stop web && docker rm web"},
+		},
+		{
+			name: "inline code in prose",
+			text: "Use `docker ps` for running containers, or `docker ps -a` to include stopped ones.",
+			want: []string{"docker ps", "docker ps -a"},
+		},
+		{
+			name: "list of inline code",
+			text: "1. `docker stop web`\n2. `docker rm web`",
+			want: []string{"docker stop web && docker rm web"},
+		},
+		{
+			name: "multiple fenced blocks",
+			text: "Stop it gracefully:\n```\ndocker stop web\n```\nor, if it hangs:\n```\ndocker kill web\n```",
+			want: []string{"docker stop web", "docker kill web"},
+		},
+		{
+			name: "code block wins over prose",
+			text: "Unlike `docker ps`, this includes stopped containers:\n```\ndocker ps -a\n```",
+			want: []string{"docker ps -a"},
+		},
+		{
+			name: "unclosed fence",
+			text: "```bash\ndocker images",
+			want: []string{"docker images"},
+		},
+		{
+			name: "no command",
+			text: "I can't help with that.",
+		},
+	}
+	for _, tt := range tests {
+		if got := ExtractDockerCommands(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: ExtractDockerCommands(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseReplyNormalizesCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: `docker exec web sh -c 'echo a\necho b'`, want: "docker exec web sh -c 'echo a\necho b'"},
+		{command: `  docker ps  `, want: "docker ps"},
+		{command: "`docker ps`", want: "docker ps"},
+		{command: "```bash\\ndocker stop web\\ndocker rm web\\n```", want: "docker stop web && docker rm web"},
+		{command: `$ docker ps -a`, want: "docker ps -a"},
+	}
+	for _, tt := range tests {
+		reply, err := ParseReply(`{"risk_level":"low","command":"` + tt.command + `"}`)
+		if err != nil {
+			t.Errorf("ParseReply with command %q: %v", tt.command, err)
+			continue
+		}
+		if reply.Command != tt.want {
+			t.Errorf("command %q became %q, want %q", tt.command, reply.Command, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ChainLink is one provider and model in a fallback chain. The first link
+// always uses the model the caller asked for; later links use Model, or the
+// provider's default if it is empty.
+type ChainLink struct {
+	Provider Provider
+	Model    string
+}
+
+// String describes the link as "provider" or "provider (model)".
+func (l ChainLink) String() string {
+	if l.Model == "" {
+		return l.Provider.Name()
+	}
+	return fmt.Sprintf("%s (%s)", l.Provider.Name(), l.Model)
+}
+
+// fallback tries each link in order until one succeeds.
+type fallback struct {
+	links      []ChainLink
+	onFallback func(failed ChainLink, err error, next ChainLink)
+}
+
+// WithFallback returns a Provider that tries links in order, moving on when
+// a link fails. onFallback, if set, is called each time it moves on. The
+// Response records which link produced it.
+func WithFallback(links []ChainLink, onFallback func(failed ChainLink, err error, next ChainLink)) Provider {
+	if len(links) == 1 {
+		return links[0].Provider
+	}
+	return &fallback{links: links, onFallback: onFallback}
+}
+
+func (f *fallback) Name() string {
+	names := make([]string, len(f.links))
+	for i, link := range f.links {
+		names[i] = link.Provider.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Capabilities reports only what every link supports, since any of them may
+// end up answering.
+func (f *fallback) Capabilities() Capabilities {
+	caps := f.links[0].Provider.Capabilities()
+	for _, link := range f.links[1:] {
+		c := link.Provider.Capabilities()
+		caps.Streaming = caps.Streaming && c.Streaming
+		caps.JSONMode = caps.JSONMode && c.JSONMode
+		caps.ToolCalling = caps.ToolCalling && c.ToolCalling
+	}
+	return caps
+}
+
+// ValidateCredentials succeeds if at least one link is usable.
+func (f *fallback) ValidateCredentials() error {
+	var errs []error
+	for _, link := range f.links {
+		err := link.Provider.ValidateCredentials()
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", link.Provider.Name(), err))
+	}
+	return errors.Join(errs...)
+}
+
+func (f *fallback) Complete(ctx context.Context, req Request) (*Response, error) {
+	return f.do(ctx, req, func(link ChainLink, req Request) (*Response, bool, error) {
+		resp, err := link.Provider.Complete(ctx, req)
+		return resp, false, err
+	})
+}
+
+// Stream falls back only while nothing has been streamed; once a link has
+// produced output, its failure is final.
+func (f *fallback) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	return f.do(ctx, req, func(link ChainLink, req Request) (*Response, bool, error) {
+		streamer, ok := link.Provider.(Streamer)
+		if !ok {
+			resp, err := completeAsStream(ctx, req, onChunk, link.Provider.Complete)
+			return resp, false, err
+		}
+		started := false
+		resp, err := streamer.Stream(ctx, req, func(chunk string) {
+			started = true
+			onChunk(chunk)
+		})
+		return resp, started, err
+	})
+}
+
+func (f *fallback) do(ctx context.Context, req Request, call func(ChainLink, Request) (*Response, bool, error)) (*Response, error) {
+	var errs []error
+	for i, link := range f.links {
+		linkReq := req
+		if i > 0 {
+			linkReq.Model = link.Model
+		}
+
+		resp, started, err := call(link, linkReq)
+		if err == nil {
+			resp.Provider = link.Provider.Name()
+			resp.Model = linkReq.Model
+			return resp, nil
+		}
+		if started || ctx.Err() != nil {
+			return nil, err
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", link, err))
+		if i+1 < len(f.links) && f.onFallback != nil {
+			f.onFallback(link, err, f.links[i+1])
+		}
+	}
+	return nil, fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// proseGate holds back the start of a streamed reply until it can tell what
+// kind of reply it is. A JSON reply has its question and explanation fields
+// passed through to out as they arrive; a plain-text prose reply is passed
+// through whole; a plain-text command is never written.
+type proseGate struct {
+	out     io.Writer
+	pending strings.Builder
+	decided bool
+	prose   bool
+	json    *jsonProse
+	wrote   bool
+}
+
+func (g *proseGate) write(chunk string) {
+	switch {
+	case g.prose:
+		g.emit(chunk)
+		return
+	case g.json != nil:
+		g.json.feed(chunk)
+		return
+	case g.decided:
+		return
+	}
+
+	g.pending.WriteString(chunk)
+	start := strings.TrimLeft(g.pending.String(), " \t\r\n")
+	if start == "" {
+		return
+	}
+
+	const prefix = "docker"
+	switch {
+	case strings.HasPrefix(start, "{"):
+		g.decided = true
+		g.json = &jsonProse{emit: g.emit, fields: map[string]bool{"question": true, "explanation": true}}
+		g.json.feed(start)
+	case strings.HasPrefix(start, "`") || strings.HasPrefix(start, "$"):
+		g.decided = true
+	case strings.HasPrefix(start, prefix):
+		g.decided = true
+	case len(start) < len(prefix) && strings.HasPrefix(prefix, start):
+		// Could still become "docker"; wait for more.
+	default:
+		g.decided = true
+		g.prose = true
+		g.emit(start)
+	}
+}
+
+func (g *proseGate) emit(text string) {
+	if text == "" {
+		return
+	}
+	g.wrote = true
+	io.WriteString(g.out, text)
+}
+
+// jsonProse scans a JSON object as it streams in and emits the decoded
+// contents of selected top-level string fields. It is deliberately forgiving:
+// it only needs to track enough structure to know which string it is in.
+type jsonProse struct {
+	emit   func(string)
+	fields map[string]bool
+
+	depth      int
+	inString   bool
+	escape     bool
+	unicode    []byte // hex digits of a \uXXXX escape being read
+	isKey      bool
+	afterColon bool
+	key        strings.Builder
+	lastKey    string
+	emitting   bool
+	emitted    bool
+	separate   bool
+}
+
+func (j *jsonProse) feed(chunk string) {
+	for _, r := range chunk {
+		j.step(r)
+	}
+}
+
+func (j *jsonProse) step(r rune) {
+	if !j.inString {
+		switch r {
+		case '{', '[':
+			j.depth++
+			j.afterColon = false
+		case '}', ']':
+			j.depth--
+		case ':':
+			j.afterColon = true
+		case ',':
+			j.afterColon = false
+		case '"':
+			j.inString = true
+			j.isKey = j.depth == 1 && !j.afterColon
+			j.key.Reset()
+			j.emitting = j.depth == 1 && j.afterColon && j.fields[j.lastKey]
+			// Separate consecutive fields, e.g. a question and an explanation.
+			j.separate = j.emitting && j.emitted
+		}
+		return
+	}
+
+	if j.unicode != nil {
+		j.unicode = append(j.unicode, byte(r))
+		if len(j.unicode) == 4 {
+			var code rune
+			for _, h := range j.unicode {
+				code = code<<4 | rune(hexValue(h))
+			}
+			j.unicode = nil
+			j.text(code)
+		}
+		return
+	}
+
+	if j.escape {
+		j.escape = false
+		switch r {
+		case 'n':
+			j.text('\n')
+		case 't':
+			j.text('\t')
+		case 'r':
+			// Dropped: the terminal only needs the newline.
+		case 'u':
+			j.unicode = make([]byte, 0, 4)
+		default:
+			// \" \\ \/ and anything unexpected stand for themselves.
+			j.text(r)
+		}
+		return
+	}
+
+	switch r {
+	case '\\':
+		j.escape = true
+	case '"':
+		j.inString = false
+		if j.isKey {
+			j.lastKey = j.key.String()
+		} else {
+			j.afterColon = false
+		}
+		j.emitting = false
+	default:
+		j.text(r)
+	}
+}
+
+// text handles one decoded character of the current string.
+func (j *jsonProse) text(r rune) {
+	if j.isKey {
+		j.key.WriteRune(r)
+		return
+	}
+	if j.emitting {
+		if r == utf8.RuneError {
+			return
+		}
+		if j.separate {
+			j.separate = false
+			j.emit("\n")
+		}
+		j.emitted = true
+		j.emit(string(r))
+	}
+}
+
+func hexValue(b byte) byte {
+	switch {
+	case b >= '0' && b <= '9':
+		return b - '0'
+	case b >= 'a' && b <= 'f':
+		return b - 'a' + 10
+	case b >= 'A' && b <= 'F':
+		return b - 'A' + 10
+	}
+	return 0
+}
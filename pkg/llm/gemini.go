@@ -0,0 +1,269 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// gemini uses Google's genai SDK rather than an OpenAI-compatible endpoint.
+type gemini struct {
+	baseURL string
+	client  *http.Client
+}
+
+func init() {
+	Register("gemini", func(opts Options) Provider {
+		return &gemini{baseURL: opts.BaseURL, client: newHTTPClient(opts)}
+	})
+}
+
+func (p *gemini) Name() string { return "gemini" }
+
+func (p *gemini) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, JSONMode: true, ToolCalling: true}
+}
+
+func (p *gemini) ValidateCredentials() error {
+	if os.Getenv("GEMINI_API_KEY") == "" {
+		return errors.New("GEMINI_API_KEY not set")
+	}
+	return nil
+}
+
+func (p *gemini) Complete(ctx context.Context, req Request) (*Response, error) {
+	client, model, contents, config, err := p.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		return nil, fmt.Errorf("gemini content generation failed: %w", err)
+	}
+
+	if len(result.Candidates) == 0 || result.Candidates[0].Content == nil || len(result.Candidates[0].Content.Parts) == 0 {
+		return nil, errors.New("gemini returned no content")
+	}
+
+	return &Response{
+		Content:   geminiText(result),
+		ToolCalls: geminiToolCalls(result),
+		Usage:     geminiUsage(result),
+	}, nil
+}
+
+func (p *gemini) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	client, model, contents, config, err := p.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var content strings.Builder
+	var toolCalls []ToolCall
+	var usage *Usage
+	for result, err := range client.Models.GenerateContentStream(ctx, model, contents, config) {
+		if err != nil {
+			return nil, fmt.Errorf("gemini content generation failed: %w", err)
+		}
+		if text := geminiText(result); text != "" {
+			content.WriteString(text)
+			onChunk(text)
+		}
+		toolCalls = append(toolCalls, geminiToolCalls(result)...)
+		// Each chunk reports the running total, so the last one counts.
+		if u := geminiUsage(result); u != nil {
+			usage = u
+		}
+	}
+
+	if content.Len() == 0 && len(toolCalls) == 0 {
+		return nil, errors.New("gemini returned no content")
+	}
+
+	return &Response{Content: content.String(), ToolCalls: toolCalls, Usage: usage}, nil
+}
+
+// geminiUsage returns the token counts of result, if it has them.
+func geminiUsage(result *genai.GenerateContentResponse) *Usage {
+	if result.UsageMetadata == nil {
+		return nil
+	}
+	return &Usage{
+		Model:            result.ModelVersion,
+		PromptTokens:     int(result.UsageMetadata.PromptTokenCount),
+		CompletionTokens: int(result.UsageMetadata.CandidatesTokenCount),
+	}
+}
+
+// geminiText returns the text parts of the first candidate. Unlike
+// GenerateContentResponse.Text, it doesn't log a warning when the reply also
+// holds function calls.
+func geminiText(result *genai.GenerateContentResponse) string {
+	if len(result.Candidates) == 0 || result.Candidates[0].Content == nil {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range result.Candidates[0].Content.Parts {
+		if part.Text != "" && !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
+
+// geminiToolCalls returns the function calls in result. Gemini doesn't
+// always assign call IDs, so missing ones are filled in from the name.
+func geminiToolCalls(result *genai.GenerateContentResponse) []ToolCall {
+	var calls []ToolCall
+	for i, call := range result.FunctionCalls() {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("%s-%d", call.Name, i)
+		}
+		calls = append(calls, ToolCall{ID: id, Name: call.Name, Args: call.Args})
+	}
+	return calls
+}
+
+// prepare validates credentials and builds the client, model name, contents
+// and generation config shared by Complete and Stream.
+func (p *gemini) prepare(ctx context.Context, req Request) (*genai.Client, string, []*genai.Content, *genai.GenerateContentConfig, error) {
+	if err := p.ValidateCredentials(); err != nil {
+		return nil, "", nil, nil, err
+	}
+
+	model := req.Model
+	if model == "" {
+		model = DefaultModel("gemini")
+	}
+
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:      os.Getenv("GEMINI_API_KEY"),
+		Backend:     genai.BackendGeminiAPI,
+		HTTPClient:  p.client,
+		HTTPOptions: genai.HTTPOptions{BaseURL: p.baseURL},
+	})
+	if err != nil {
+		return nil, "", nil, nil, fmt.Errorf("failed to create a new Gemini client: %w", err)
+	}
+
+	// Combine system prompt and user prompt
+	fullPrompt := fmt.Sprintf("%s\n\nUser: %s\nAssistant:", req.SystemPrompt, req.Prompt)
+	contents := genai.Text(fullPrompt)
+	multiTurn := len(req.History) > 0 || len(req.ToolMessages) > 0
+	if multiTurn {
+		// The system prompt goes in a config field so the history can be
+		// sent as proper user and model turns.
+		contents = nil
+		for _, m := range req.History {
+			role := genai.Role(genai.RoleUser)
+			if m.Role == RoleAssistant {
+				role = genai.RoleModel
+			}
+			contents = append(contents, genai.NewContentFromText(m.Content, role))
+		}
+		contents = append(contents, genai.NewContentFromText(req.Prompt, genai.RoleUser))
+		contents = append(contents, geminiToolContents(req.ToolMessages)...)
+	}
+
+	config := &genai.GenerateContentConfig{}
+	if multiTurn {
+		config.SystemInstruction = genai.NewContentFromText(req.SystemPrompt, genai.RoleUser)
+	}
+	if len(req.Tools) > 0 {
+		config.Tools = []*genai.Tool{{FunctionDeclarations: geminiFunctions(req.Tools)}}
+	} else if req.JSON {
+		// Gemini rejects a JSON response type alongside function calling,
+		// so with tools the prompt alone asks for JSON.
+		config.ResponseMIMEType = "application/json"
+		config.ResponseSchema = geminiReplySchema
+	}
+
+	return client, model, contents, config, nil
+}
+
+// geminiToolContents converts tool calls and results to Gemini contents.
+func geminiToolContents(messages []Message) []*genai.Content {
+	var contents []*genai.Content
+	for _, m := range messages {
+		if m.Role == RoleTool {
+			contents = append(contents, &genai.Content{
+				Role:  genai.RoleUser,
+				Parts: []*genai.Part{genai.NewPartFromFunctionResponse(m.Name, map[string]any{"output": m.Content})},
+			})
+			continue
+		}
+		var parts []*genai.Part
+		if m.Content != "" {
+			parts = append(parts, genai.NewPartFromText(m.Content))
+		}
+		for _, call := range m.ToolCalls {
+			parts = append(parts, genai.NewPartFromFunctionCall(call.Name, call.Args))
+		}
+		contents = append(contents, &genai.Content{Role: genai.RoleModel, Parts: parts})
+	}
+	return contents
+}
+
+// geminiFunctions converts tool definitions to Gemini function declarations.
+func geminiFunctions(tools []ToolDef) []*genai.FunctionDeclaration {
+	decls := make([]*genai.FunctionDeclaration, len(tools))
+	for i, tool := range tools {
+		decls[i] = &genai.FunctionDeclaration{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  geminiSchema(tool.Parameters),
+		}
+	}
+	return decls
+}
+
+// geminiSchema converts the subset of JSON schema used by tool definitions
+// (objects, strings, integers, booleans, enums and arrays) to a genai.Schema.
+func geminiSchema(schema map[string]interface{}) *genai.Schema {
+	if schema == nil {
+		return nil
+	}
+	s := &genai.Schema{}
+	if t, ok := schema["type"].(string); ok {
+		s.Type = genai.Type(strings.ToUpper(t))
+	}
+	if d, ok := schema["description"].(string); ok {
+		s.Description = d
+	}
+	if enum, ok := schema["enum"].([]string); ok {
+		s.Enum = enum
+	}
+	if required, ok := schema["required"].([]string); ok {
+		s.Required = required
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		s.Items = geminiSchema(items)
+	}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		s.Properties = map[string]*genai.Schema{}
+		for name, prop := range props {
+			if propSchema, ok := prop.(map[string]interface{}); ok {
+				s.Properties[name] = geminiSchema(propSchema)
+			}
+		}
+	}
+	return s
+}
+
+// geminiReplySchema is replySchema in the genai SDK's own schema type. Gemini
+// emits properties in alphabetical order unless told otherwise, so the order
+// that puts prose before the command is spelled out.
+var geminiReplySchema = func() *genai.Schema {
+	s := geminiSchema(replySchema)
+	s.PropertyOrdering = append(slices.Clone(replyFields), "candidates")
+	s.Properties["candidates"].Items.PropertyOrdering = []string{"explanation", "risk_level", "command"}
+	return s
+}()
@@ -0,0 +1,267 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// MockRules is the rules file of the mock provider.
+type MockRules struct {
+	Rules []MockRule `json:"rules"`
+	// Default answers requests that match no rule. If it is nil, they get
+	// a clarifying question.
+	Default *MockReply `json:"default,omitempty"`
+}
+
+// MockRule maps matching requests to canned replies.
+type MockRule struct {
+	// Match is a regular expression. Its submatches are available to
+	// templates as .Match.
+	Match string `json:"match,omitempty"`
+	// Keywords match if every one appears in the request, ignoring case.
+	Keywords []string `json:"keywords,omitempty"`
+	// Replies are given in turn each time the rule matches, so a rule can
+	// script a clarification followed by a command, or a failing command
+	// followed by its repair. The last reply repeats.
+	Replies []MockReply `json:"replies"`
+
+	re *regexp.Regexp
+}
+
+// MockReply is one canned answer. Its text fields are templates; see
+// mockTemplateData.
+type MockReply struct {
+	Reply
+	// Text, if set, is sent as is instead of a JSON Reply, to exercise
+	// models that answer in plain text.
+	Text string `json:"text,omitempty"`
+	// Error, if set, fails the call instead. Status makes it a StatusError,
+	// e.g. 429 to exercise retries and fallback.
+	Error  string `json:"error,omitempty"`
+	Status int    `json:"status,omitempty"`
+	// Delay is how long to wait before answering, e.g. "2s".
+	Delay string `json:"delay,omitempty"`
+}
+
+// mockTemplateData is what the templates in a MockReply can refer to.
+type mockTemplateData struct {
+	// Request is the prompt being answered.
+	Request string
+	// Match holds the submatches of the rule's regular expression.
+	Match []string
+	// Containers are the names in Request.Containers, and Container is the
+	// first of them.
+	Containers []string
+	Container  string
+}
+
+// mock answers from a local rules file without any network access, for
+// demos and for exercising the whole flow without an API key.
+type mock struct {
+	rulesFile string
+
+	mu    sync.Mutex
+	turns map[int]int // rule index -> times matched
+}
+
+func init() {
+	Register("mock", func(opts Options) Provider {
+		rulesFile := opts.RulesFile
+		if rulesFile == "" {
+			rulesFile = os.Getenv("DOCKER_AI_MOCK_RULES")
+		}
+		if rulesFile == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				rulesFile = filepath.Join(home, ".docker-ai-mock.json")
+			}
+		}
+		return &mock{rulesFile: rulesFile, turns: map[int]int{}}
+	})
+}
+
+func (p *mock) Name() string { return "mock" }
+
+func (p *mock) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, JSONMode: true}
+}
+
+// ValidateCredentials checks that the rules file can be loaded.
+func (p *mock) ValidateCredentials() error {
+	_, err := p.load()
+	return err
+}
+
+// load reads the rules file. It is read on every call, so rules can be
+// edited while a demo is running.
+func (p *mock) load() (*MockRules, error) {
+	if p.rulesFile == "" {
+		return nil, errors.New("mock provider needs a rules file (set DOCKER_AI_MOCK_RULES)")
+	}
+	data, err := os.ReadFile(p.rulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read mock rules: %w", err)
+	}
+	var rules MockRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid mock rules %s: %w", p.rulesFile, err)
+	}
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		if rule.Match == "" && len(rule.Keywords) == 0 {
+			return nil, fmt.Errorf("mock rule %d has neither match nor keywords", i+1)
+		}
+		if len(rule.Replies) == 0 {
+			return nil, fmt.Errorf("mock rule %d has no replies", i+1)
+		}
+		if rule.Match != "" {
+			if rule.re, err = regexp.Compile(rule.Match); err != nil {
+				return nil, fmt.Errorf("mock rule %d: %w", i+1, err)
+			}
+		}
+	}
+	return &rules, nil
+}
+
+func (p *mock) Complete(ctx context.Context, req Request) (*Response, error) {
+	rules, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, data := p.pick(rules, req)
+	if reply.Delay != "" {
+		delay, err := time.ParseDuration(reply.Delay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mock delay %q: %w", reply.Delay, err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if reply.Error != "" {
+		err := errors.New(reply.Error)
+		if reply.Status != 0 {
+			return nil, &StatusError{StatusCode: reply.Status, Err: err}
+		}
+		return nil, err
+	}
+	content, err := reply.render(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Content: content}, nil
+}
+
+// Stream sends the reply a few characters at a time, like a real model.
+func (p *mock) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	resp, err := p.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for text := resp.Content; text != ""; {
+		n := min(len(text), 8)
+		onChunk(text[:n])
+		text = text[n:]
+	}
+	return resp, nil
+}
+
+// pick finds the first matching rule and returns its next reply.
+func (p *mock) pick(rules *MockRules, req Request) (MockReply, mockTemplateData) {
+	data := mockTemplateData{Request: req.Prompt, Containers: req.Containers}
+	if len(data.Containers) > 0 {
+		data.Container = data.Containers[0]
+	}
+
+	request := strings.ToLower(req.Prompt)
+	for i, rule := range rules.Rules {
+		data.Match = nil
+		if rule.re != nil {
+			match := rule.re.FindStringSubmatch(req.Prompt)
+			if match == nil {
+				continue
+			}
+			data.Match = match
+		}
+		if !containsAll(request, rule.Keywords) {
+			continue
+		}
+
+		p.mu.Lock()
+		turn := min(p.turns[i], len(rule.Replies)-1)
+		p.turns[i]++
+		p.mu.Unlock()
+		return rule.Replies[turn], data
+	}
+
+	if rules.Default != nil {
+		return *rules.Default, data
+	}
+	return MockReply{Reply: Reply{
+		NeedsClarification: true,
+		Question:           "The mock provider has no rule for that request.",
+	}}, data
+}
+
+func containsAll(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if !strings.Contains(text, strings.ToLower(keyword)) {
+			return false
+		}
+	}
+	return true
+}
+
+// render executes the reply's templates and returns what the model would
+// have sent.
+func (r MockReply) render(data mockTemplateData) (string, error) {
+	expand := func(text string) (string, error) {
+		if !strings.Contains(text, "{{") {
+			return text, nil
+		}
+		tmpl, err := template.New("mock").Funcs(promptFuncs).Parse(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid mock template %q: %w", text, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("mock template %q: %w", text, err)
+		}
+		return b.String(), nil
+	}
+
+	if r.Text != "" {
+		return expand(r.Text)
+	}
+	reply := r.Reply
+	fields := []*string{&reply.Command, &reply.Explanation, &reply.Question}
+	reply.Candidates = slices.Clone(reply.Candidates)
+	for i := range reply.Candidates {
+		fields = append(fields, &reply.Candidates[i].Command, &reply.Candidates[i].Explanation)
+	}
+	for _, field := range fields {
+		text, err := expand(*field)
+		if err != nil {
+			return "", err
+		}
+		*field = text
+	}
+	if reply.RiskLevel == "" {
+		reply.RiskLevel = RiskLow
+	}
+	content, err := json.Marshal(reply)
+	return string(content), err
+}
@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMock writes rules to a file and returns a mock provider reading it.
+func newMock(t *testing.T, rules string) Provider {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProvider("mock", Options{RulesFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// askMock sends req to p and parses the reply.
+func askMock(t *testing.T, p Provider, req Request) *Reply {
+	t.Helper()
+	resp, err := p.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := ParseReply(resp.Content)
+	if err != nil {
+		t.Fatalf("ParseReply(%q): %v", resp.Content, err)
+	}
+	return reply
+}
+
+const mockRules = `{
+	"rules": [
+		{"match": "(?i)restart (\\w+)", "replies": [{"command": "docker restart {{index .Match 1}}", "risk_level": "medium"}]},
+		{"keywords": ["Logs", "last"], "replies": [{"command": "docker logs --tail 20 {{.Container}}"}]},
+		{"keywords": ["logs"], "replies": [{"command": "docker logs {{.Container}}"}]},
+		{"keywords": ["all"], "replies": [{"text": "Run ` + "`docker ps -a`" + ` for {{len .Containers}} containers."}]},
+		{"keywords": ["clean"], "replies": [
+			{"needs_clarification": true, "question": "Which containers?"},
+			{"command": "docker container prune -f", "risk_level": "high"}
+		]}
+	],
+	"default": {"explanation": "No rule for {{printf \"%q\" .Request}}."}
+}`
+
+func TestMockRules(t *testing.T) {
+	containers := []string{"web", "db"}
+	tests := []struct {
+		prompt      string
+		wantCommand string
+		wantRisk    string
+		wantText    string
+	}{
+		// A regular expression's submatches are available as .Match.
+		{prompt: "please Restart cache", wantCommand: "docker restart cache", wantRisk: RiskMedium},
+		// Keywords ignore case, and the first matching rule wins.
+		{prompt: "show the LAST logs", wantCommand: "docker logs --tail 20 web", wantRisk: RiskLow},
+		{prompt: "show the logs", wantCommand: "docker logs web", wantRisk: RiskLow},
+		// A text reply is sent as is.
+		{prompt: "list all", wantCommand: "docker ps -a"},
+		// The default reply answers everything else.
+		{prompt: "hello", wantText: `No rule for "hello".`},
+	}
+	p := newMock(t, mockRules)
+	for _, tt := range tests {
+		reply := askMock(t, p, Request{Prompt: tt.prompt, Containers: containers})
+		if reply.Command != tt.wantCommand {
+			t.Errorf("%q: Command = %q, want %q", tt.prompt, reply.Command, tt.wantCommand)
+		}
+		if tt.wantRisk != "" && reply.RiskLevel != tt.wantRisk {
+			t.Errorf("%q: RiskLevel = %q, want %q", tt.prompt, reply.RiskLevel, tt.wantRisk)
+		}
+		if reply.Explanation != tt.wantText && tt.wantText != "" {
+			t.Errorf("%q: Explanation = %q, want %q", tt.prompt, reply.Explanation, tt.wantText)
+		}
+	}
+}
+
+func TestMockContainersFromRequest(t *testing.T) {
+	p := newMock(t, mockRules)
+	// The container list comes from the request, not from the system
+	// prompt, so a custom prompt can't hide it.
+	reply := askMock(t, p, Request{SystemPrompt: "Custom prompt without a container list.", Prompt: "logs", Containers: []string{"api"}})
+	if reply.Command != "docker logs api" {
+		t.Errorf("Command = %q, want docker logs api", reply.Command)
+	}
+	resp, err := p.Complete(context.Background(), Request{Prompt: "list all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Run `docker ps -a` for 0 containers."; resp.Content != want {
+		t.Errorf("Content = %q, want %q", resp.Content, want)
+	}
+}
+
+func TestMockTurns(t *testing.T) {
+	p := newMock(t, mockRules)
+	first := askMock(t, p, Request{Prompt: "clean up"})
+	if !first.NeedsClarification || first.Question != "Which containers?" {
+		t.Errorf("first reply = %+v, want the clarifying question", first)
+	}
+	// The last reply repeats.
+	for i := 0; i < 2; i++ {
+		reply := askMock(t, p, Request{Prompt: "clean up"})
+		if reply.Command != "docker container prune -f" || reply.RiskLevel != RiskHigh {
+			t.Errorf("reply %d = %+v, want the prune command", i+2, reply)
+		}
+	}
+}
+
+func TestMockNoRule(t *testing.T) {
+	p := newMock(t, `{"rules": [{"keywords": ["ps"], "replies": [{"command": "docker ps"}]}]}`)
+	reply := askMock(t, p, Request{Prompt: "hello"})
+	if !reply.NeedsClarification || reply.Command != "" {
+		t.Errorf("reply = %+v, want a clarifying question", reply)
+	}
+}
+
+func TestMockErrors(t *testing.T) {
+	p := newMock(t, `{"rules": [
+		{"keywords": ["busy"], "replies": [{"error": "rate limited", "status": 429}]},
+		{"keywords": ["broken"], "replies": [{"error": "connection reset"}]},
+		{"keywords": ["slow"], "replies": [{"command": "docker ps", "delay": "1h"}]},
+		{"keywords": ["quick"], "replies": [{"command": "docker ps", "delay": "10ms"}]},
+		{"keywords": ["bad delay"], "replies": [{"command": "docker ps", "delay": "soon"}]},
+		{"keywords": ["bad template"], "replies": [{"command": "docker logs {{.Nope}}"}]}
+	]}`)
+
+	_, err := p.Complete(context.Background(), Request{Prompt: "busy"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 429 || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("error = %v, want a 429 StatusError", err)
+	}
+	_, err = p.Complete(context.Background(), Request{Prompt: "broken"})
+	if err == nil || errors.As(err, &statusErr) || err.Error() != "connection reset" {
+		t.Errorf("error = %v, want a plain connection reset error", err)
+	}
+
+	start := time.Now()
+	if _, err := p.Complete(context.Background(), Request{Prompt: "quick"}); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 10*time.Millisecond {
+		t.Errorf("answered after %s, want a 10ms delay", waited)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Complete(ctx, Request{Prompt: "slow"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the delay cut short by the context", err)
+	}
+
+	for _, prompt := range []string{"bad delay", "bad template"} {
+		if _, err := p.Complete(context.Background(), Request{Prompt: prompt}); err == nil {
+			t.Errorf("%q: no error", prompt)
+		}
+	}
+}
+
+func TestMockRulesFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr string
+	}{
+		{name: "invalid JSON", rules: `{"rules": [`, wantErr: "invalid mock rules"},
+		{name: "rule without a match", rules: `{"rules": [{"replies": [{"command": "docker ps"}]}]}`, wantErr: "neither match nor keywords"},
+		{name: "rule without replies", rules: `{"rules": [{"keywords": ["ps"]}]}`, wantErr: "no replies"},
+		{name: "invalid regular expression", rules: `{"rules": [{"match": "(", "replies": [{"command": "docker ps"}]}]}`, wantErr: "mock rule 1"},
+	}
+	for _, tt := range tests {
+		err := newMock(t, tt.rules).ValidateCredentials()
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want it to mention %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	p, err := NewProvider("mock", Options{RulesFile: filepath.Join(t.TempDir(), "missing.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ValidateCredentials(); err == nil || !strings.Contains(err.Error(), "could not read mock rules") {
+		t.Errorf("missing rules file: error = %v", err)
+	}
+	if _, err := p.Complete(context.Background(), Request{Prompt: "ps"}); err == nil {
+		t.Error("Complete succeeded without a rules file")
+	}
+}
+
+func TestMockStream(t *testing.T) {
+	p := newMock(t, mockRules)
+	var chunks []string
+	resp, err := p.(Streamer).Stream(context.Background(), Request{Prompt: "restart web"}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 || strings.Join(chunks, "") != resp.Content {
+		t.Errorf("streamed %q, want %q in several chunks", chunks, resp.Content)
+	}
+}
@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const defaultOllamaHost = "http://localhost:11434"
+
+// ollamaResponse is the non-streaming reply from Ollama's /api/chat endpoint.
+type ollamaResponse struct {
+	Model   string `json:"model"`
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Error string `json:"error"`
+	// Token counts are only set on the final message.
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+// usage returns the token counts of a final message.
+func (r *ollamaResponse) usage() *Usage {
+	return &Usage{Model: r.Model, PromptTokens: r.PromptEvalCount, CompletionTokens: r.EvalCount}
+}
+
+// ollama talks to a local Ollama server, so requests never leave the machine.
+type ollama struct {
+	host   string
+	client *http.Client
+}
+
+func init() {
+	Register("ollama", func(opts Options) Provider {
+		host := opts.BaseURL
+		if host == "" {
+			host = os.Getenv("OLLAMA_HOST")
+		}
+		if host == "" {
+			host = defaultOllamaHost
+		}
+		// OLLAMA_HOST is commonly set without a scheme, e.g. "127.0.0.1:11434".
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return &ollama{host: strings.TrimRight(host, "/"), client: newHTTPClient(opts)}
+	})
+}
+
+func (p *ollama) Name() string { return "ollama" }
+
+func (p *ollama) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, JSONMode: true}
+}
+
+// ValidateCredentials always succeeds: a local Ollama server needs no key.
+func (p *ollama) ValidateCredentials() error {
+	return nil
+}
+
+func (p *ollama) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := p.post(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ollamaResp ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
+		return nil, err
+	}
+	if ollamaResp.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", ollamaResp.Error)
+	}
+	if ollamaResp.Message.Content == "" {
+		return nil, errors.New("ollama returned no content")
+	}
+
+	return &Response{Content: ollamaResp.Message.Content, Usage: ollamaResp.usage()}, nil
+}
+
+// Stream reads Ollama's streaming reply, which is one JSON object per line
+// rather than server-sent events.
+func (p *ollama) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	resp, err := p.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage *Usage
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk struct {
+			ollamaResponse
+			Done bool `json:"done"`
+		}
+		if err := decoder.Decode(&chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama: %s", chunk.Error)
+		}
+		if text := chunk.Message.Content; text != "" {
+			content.WriteString(text)
+			onChunk(text)
+		}
+		if chunk.Done {
+			usage = chunk.usage()
+			break
+		}
+	}
+
+	if content.Len() == 0 {
+		return nil, errors.New("ollama returned no content")
+	}
+
+	return &Response{Content: content.String(), Usage: usage}, nil
+}
+
+// post sends a chat request and returns the response once its status has
+// been checked. The caller must close the body.
+func (p *ollama) post(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	model := req.Model
+	if model == "" {
+		model = DefaultModel("ollama")
+	}
+
+	payload := map[string]interface{}{
+		"model":    model,
+		"messages": chatMessages(req, true),
+		"stream":   stream,
+		"options": map[string]interface{}{
+			"temperature": 0.1,
+		},
+	}
+	if req.JSON {
+		payload["format"] = replySchema
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.host+"/api/chat", bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach Ollama at %s (is 'ollama serve' running?): %w", p.host, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		var ollamaResp ollamaResponse
+		if json.Unmarshal(body, &ollamaResp) == nil && ollamaResp.Error != "" {
+			return nil, newStatusError(resp, fmt.Errorf("ollama request failed with status %d: %s", resp.StatusCode, ollamaResp.Error))
+		}
+		return nil, newStatusError(resp, fmt.Errorf("ollama request failed with status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	return resp, nil
+}
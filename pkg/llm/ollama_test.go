@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ollamaServer stands in for a local Ollama server. handle answers
+// /api/chat after the request has been decoded.
+func ollamaServer(t *testing.T, handle func(w http.ResponseWriter, payload map[string]interface{})) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handle(w, payload)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newOllama(t *testing.T, baseURL string) Provider {
+	t.Helper()
+	p, err := NewProvider("ollama", Options{BaseURL: baseURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOllamaComplete(t *testing.T) {
+	srv := ollamaServer(t, func(w http.ResponseWriter, payload map[string]interface{}) {
+		if payload["stream"] != false {
+			t.Errorf("stream = %v, want false", payload["stream"])
+		}
+		if payload["model"] != "llama3.2" {
+			t.Errorf("model = %v, want the default llama3.2", payload["model"])
+		}
+		if _, ok := payload["format"]; !ok {
+			t.Error("JSON request sent without a format schema")
+		}
+		messages, _ := payload["messages"].([]interface{})
+		if len(messages) != 2 {
+			t.Errorf("got %d messages, want system and user", len(messages))
+		}
+		fmt.Fprint(w, `{"model":"llama3.2","message":{"role":"assistant","content":"{\"command\":\"docker ps\"}"},"done":true,"prompt_eval_count":12,"eval_count":5}`)
+	})
+
+	resp, err := newOllama(t, srv.URL).Complete(context.Background(), Request{SystemPrompt: "system", Prompt: "list containers", JSON: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"command":"docker ps"}` {
+		t.Errorf("Content = %q", resp.Content)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 5 {
+		t.Errorf("Usage = %+v, want 12 prompt and 5 completion tokens", resp.Usage)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	srv := ollamaServer(t, func(w http.ResponseWriter, payload map[string]interface{}) {
+		if payload["stream"] != true {
+			t.Errorf("stream = %v, want true", payload["stream"])
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range []string{
+			`{"model":"llama3.2","message":{"content":"docker "},"done":false}`,
+			`{"model":"llama3.2","message":{"content":"ps -a"},"done":false}`,
+			`{"model":"llama3.2","message":{"content":""},"done":true,"prompt_eval_count":20,"eval_count":3}`,
+		} {
+			fmt.Fprintln(w, line)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	var chunks []string
+	resp, err := newOllama(t, srv.URL).(Streamer).Stream(context.Background(), Request{Prompt: "list all containers"}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(chunks, "|"); got != "docker |ps -a" {
+		t.Errorf("chunks = %q", got)
+	}
+	if resp.Content != "docker ps -a" {
+		t.Errorf("Content = %q", resp.Content)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 20 || resp.Usage.CompletionTokens != 3 {
+		t.Errorf("Usage = %+v, want 20 prompt and 3 completion tokens", resp.Usage)
+	}
+}
+
+func TestOllamaErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		stream     bool
+		wantErr    string
+		wantStatus int
+	}{
+		{
+			name:       "error body with status",
+			status:     http.StatusNotFound,
+			body:       `{"error":"model \"llama3.2\" not found, try pulling it first"}`,
+			wantErr:    `ollama request failed with status 404: model "llama3.2" not found`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "plain text body",
+			status:     http.StatusInternalServerError,
+			body:       "boom",
+			wantErr:    "ollama request failed with status 500: boom",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:    "error body with 200",
+			status:  http.StatusOK,
+			body:    `{"error":"out of memory"}`,
+			wantErr: "ollama: out of memory",
+		},
+		{
+			name:    "error line in stream",
+			status:  http.StatusOK,
+			body:    `{"message":{"content":"docker"},"done":false}` + "\n" + `{"error":"connection reset"}` + "\n",
+			stream:  true,
+			wantErr: "ollama: connection reset",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := ollamaServer(t, func(w http.ResponseWriter, payload map[string]interface{}) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+			p := newOllama(t, srv.URL)
+
+			var err error
+			if tt.stream {
+				_, err = p.(Streamer).Stream(context.Background(), Request{Prompt: "hi"}, func(string) {})
+			} else {
+				_, err = p.Complete(context.Background(), Request{Prompt: "hi"})
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) != (tt.wantStatus != 0) {
+				t.Fatalf("StatusError = %v, want one only for a non-200 status", statusErr)
+			}
+			if tt.wantStatus != 0 && statusErr.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", statusErr.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestOllamaHost(t *testing.T) {
+	srv := ollamaServer(t, func(w http.ResponseWriter, payload map[string]interface{}) {
+		fmt.Fprint(w, `{"message":{"content":"docker ps"},"done":true}`)
+	})
+	// OLLAMA_HOST is often set to a bare host and port.
+	t.Setenv("OLLAMA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+
+	p := newOllama(t, "")
+	if got := p.(*ollama).host; got != srv.URL {
+		t.Errorf("host = %q, want %q", got, srv.URL)
+	}
+	if _, err := p.Complete(context.Background(), Request{Prompt: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,429 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LLMResponse represents a minimal OpenAI-compatible response
+type LLMResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// openAIUsage is the token count block of a chat-completions response.
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// toUsage converts u, which may be nil, for a reply from model.
+func (u *openAIUsage) toUsage(model string) *Usage {
+	if u == nil {
+		return nil
+	}
+	return &Usage{Model: model, PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
+
+// openAIToolCall is a function call in a chat-completions message. In a
+// stream, Index says which call a fragment belongs to and Arguments arrives
+// in pieces.
+type openAIToolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// toToolCalls decodes the calls' JSON arguments.
+func toToolCalls(calls []openAIToolCall) ([]ToolCall, error) {
+	toolCalls := make([]ToolCall, 0, len(calls))
+	for _, call := range calls {
+		args := map[string]interface{}{}
+		if strings.TrimSpace(call.Function.Arguments) != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("malformed arguments for tool %s: %w", call.Function.Name, err)
+			}
+		}
+		toolCalls = append(toolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Args: args})
+	}
+	return toolCalls, nil
+}
+
+// openAITools converts tool definitions to the chat-completions format.
+func openAITools(tools []ToolDef) []map[string]interface{} {
+	defs := make([]map[string]interface{}, len(tools))
+	for i, tool := range tools {
+		defs[i] = map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			},
+		}
+	}
+	return defs
+}
+
+// apiError is the error body OpenAI-compatible APIs return. Azure adds the
+// content filter verdict under innererror.
+type apiError struct {
+	Error struct {
+		Message    string `json:"message"`
+		Type       string `json:"type"`
+		Code       string `json:"code"`
+		InnerError struct {
+			Code                string                        `json:"code"`
+			ContentFilterResult map[string]contentFilterEntry `json:"content_filter_result"`
+		} `json:"innererror"`
+	} `json:"error"`
+}
+
+// errContentFiltered is returned when the reply itself was withheld by the
+// provider's content filter.
+var errContentFiltered = errors.New("the response was blocked by the provider's content filter; try rephrasing your request")
+
+// contentFilterEntry is one category of Azure's content filter verdict.
+type contentFilterEntry struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity"`
+}
+
+// openAICompatible talks to any backend that implements the OpenAI
+// chat-completions API, such as Groq, OpenAI itself, vLLM, LM Studio,
+// LiteLLM or Docker Model Runner.
+type openAICompatible struct {
+	name         string
+	baseURL      string
+	apiKeyEnv    string
+	authHeader   string
+	authScheme   string
+	keyOptional  bool
+	defaultModel string
+	// jsonMode, toolCalling and streamUsage are set for backends known to
+	// accept response_format, tools and stream_options.
+	jsonMode    bool
+	toolCalling bool
+	streamUsage bool
+	client      *http.Client
+}
+
+func init() {
+	Register("groq", func(opts Options) Provider {
+		return newOpenAICompatible(opts, openAICompatible{
+			name:         "groq",
+			baseURL:      "https://api.groq.com/openai/v1",
+			apiKeyEnv:    "GROQ_API_KEY",
+			defaultModel: DefaultModel("groq"),
+			jsonMode:     true,
+			toolCalling:  true,
+			streamUsage:  true,
+		})
+	})
+	Register("openai", func(opts Options) Provider {
+		return newOpenAICompatible(opts, openAICompatible{
+			name:         "openai",
+			baseURL:      "https://api.openai.com/v1",
+			apiKeyEnv:    "OPENAI_API_KEY",
+			defaultModel: DefaultModel("openai"),
+			jsonMode:     true,
+			toolCalling:  true,
+			streamUsage:  true,
+		})
+	})
+	// Local servers usually need no key, so one is only sent when set.
+	Register("openai-compatible", func(opts Options) Provider {
+		return newOpenAICompatible(opts, openAICompatible{
+			name:        "openai-compatible",
+			apiKeyEnv:   "LLM_API_KEY",
+			keyOptional: true,
+		})
+	})
+}
+
+// newOpenAICompatible applies the user's options on top of a provider's
+// defaults.
+func newOpenAICompatible(opts Options, p openAICompatible) *openAICompatible {
+	if opts.BaseURL != "" {
+		p.baseURL = opts.BaseURL
+	}
+	p.baseURL = strings.TrimRight(p.baseURL, "/")
+	if opts.APIKeyEnv != "" {
+		p.apiKeyEnv = opts.APIKeyEnv
+	}
+	p.authHeader = "Authorization"
+	if opts.AuthHeader != "" {
+		p.authHeader = opts.AuthHeader
+	}
+	p.authScheme = "Bearer"
+	if opts.AuthScheme != "" {
+		p.authScheme = opts.AuthScheme
+	}
+	p.client = newHTTPClient(opts)
+	return &p
+}
+
+func (p *openAICompatible) Name() string { return p.name }
+
+func (p *openAICompatible) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, JSONMode: p.jsonMode, ToolCalling: p.toolCalling}
+}
+
+func (p *openAICompatible) ValidateCredentials() error {
+	if p.baseURL == "" {
+		return fmt.Errorf("%s requires a base URL (set --llm-base-url)", p.name)
+	}
+	if !p.keyOptional && os.Getenv(p.apiKeyEnv) == "" {
+		return fmt.Errorf("%s not set", p.apiKeyEnv)
+	}
+	return nil
+}
+
+// setAuth adds the API key header, if there is a key to send.
+func (p *openAICompatible) setAuth(req *http.Request) {
+	apiKey := os.Getenv(p.apiKeyEnv)
+	if apiKey == "" {
+		return
+	}
+	if p.authScheme == "none" {
+		req.Header.Set(p.authHeader, apiKey)
+		return
+	}
+	req.Header.Set(p.authHeader, p.authScheme+" "+apiKey)
+}
+
+func (p *openAICompatible) Complete(ctx context.Context, req Request) (*Response, error) {
+	if err := p.ValidateCredentials(); err != nil {
+		return nil, err
+	}
+	return p.complete(ctx, req, p.baseURL+"/chat/completions")
+}
+
+// complete posts a chat-completions request to url. It is shared with
+// providers, like Azure, that only differ in how the URL is built.
+func (p *openAICompatible) complete(ctx context.Context, req Request, url string) (*Response, error) {
+	resp, err := p.post(ctx, req, url, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var llmResponse LLMResponse
+	if err := json.Unmarshal(body, &llmResponse); err != nil {
+		return nil, err
+	}
+
+	if len(llmResponse.Choices) == 0 {
+		return nil, errors.New("no response from LLM")
+	}
+	if llmResponse.Choices[0].FinishReason == "content_filter" {
+		return nil, errContentFiltered
+	}
+
+	message := llmResponse.Choices[0].Message
+	toolCalls, err := toToolCalls(message.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Content:   message.Content,
+		ToolCalls: toolCalls,
+		Usage:     llmResponse.Usage.toUsage(llmResponse.Model),
+	}, nil
+}
+
+func (p *openAICompatible) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	if err := p.ValidateCredentials(); err != nil {
+		return nil, err
+	}
+	return p.stream(ctx, req, p.baseURL+"/chat/completions", onChunk)
+}
+
+// stream is the streaming counterpart of complete. The reply arrives as
+// server-sent events, each carrying a delta of the message.
+func (p *openAICompatible) stream(ctx context.Context, req Request, url string, onChunk func(string)) (*Response, error) {
+	resp, err := p.post(ctx, req, url, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var finishReason string
+	var calls []openAIToolCall
+	var model string
+	var usage *openAIUsage
+	err = readSSE(resp.Body, func(_, data string) error {
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk struct {
+			Model   string `json:"model"`
+			Choices []struct {
+				Delta struct {
+					Content   string           `json:"content"`
+					ToolCalls []openAIToolCall `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			// Usage comes in a final chunk with no choices.
+			Usage *openAIUsage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("malformed stream event: %w", err)
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		if chunk.Choices[0].FinishReason != "" {
+			finishReason = chunk.Choices[0].FinishReason
+		}
+		if text := chunk.Choices[0].Delta.Content; text != "" {
+			content.WriteString(text)
+			onChunk(text)
+		}
+		// Tool calls arrive as fragments: the first carries the ID and name,
+		// later ones append to the arguments.
+		for _, fragment := range chunk.Choices[0].Delta.ToolCalls {
+			for len(calls) <= fragment.Index {
+				calls = append(calls, openAIToolCall{Index: len(calls)})
+			}
+			call := &calls[fragment.Index]
+			if fragment.ID != "" {
+				call.ID = fragment.ID
+			}
+			call.Function.Name += fragment.Function.Name
+			call.Function.Arguments += fragment.Function.Arguments
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if finishReason == "content_filter" {
+		return nil, errContentFiltered
+	}
+	toolCalls, err := toToolCalls(calls)
+	if err != nil {
+		return nil, err
+	}
+	if content.Len() == 0 && len(toolCalls) == 0 {
+		return nil, errors.New("no response from LLM")
+	}
+
+	return &Response{Content: content.String(), ToolCalls: toolCalls, Usage: usage.toUsage(model)}, nil
+}
+
+// post sends a chat-completions request and returns the response once its
+// status has been checked. The caller must close the body.
+func (p *openAICompatible) post(ctx context.Context, req Request, url string, stream bool) (*http.Response, error) {
+	model := req.Model
+	if model == "" {
+		model = p.defaultModel
+	}
+	if model == "" {
+		return nil, fmt.Errorf("%s needs a model (use --model)", p.name)
+	}
+
+	payload := map[string]interface{}{
+		"model":       model,
+		"messages":    chatMessages(req, true),
+		"temperature": 0.1,
+		"max_tokens":  1024,
+		"top_p":       1,
+		"stream":      stream,
+	}
+	if stream && p.streamUsage {
+		payload["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	if req.JSON && p.jsonMode {
+		payload["response_format"] = map[string]string{"type": "json_object"}
+	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = openAITools(req.Tools)
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, err
+	}
+
+	p.setAuth(httpReq)
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, newStatusError(resp, describeAPIError(resp.StatusCode, bodyBytes))
+	}
+
+	return resp, nil
+}
+
+// describeAPIError turns a failed response into an error, calling out
+// content filter rejections so the user knows to rephrase.
+func describeAPIError(status int, body []byte) error {
+	var apiErr apiError
+	if json.Unmarshal(body, &apiErr) != nil || apiErr.Error.Message == "" {
+		return fmt.Errorf("LLM API request failed with status %d: %s", status, string(body))
+	}
+
+	if apiErr.Error.Code == "content_filter" || apiErr.Error.InnerError.Code == "ResponsibleAIPolicyViolation" {
+		var categories []string
+		for category, result := range apiErr.Error.InnerError.ContentFilterResult {
+			if result.Filtered {
+				categories = append(categories, fmt.Sprintf("%s (%s)", category, result.Severity))
+			}
+		}
+		sort.Strings(categories)
+		if len(categories) > 0 {
+			return fmt.Errorf("the request was blocked by the provider's content filter: %s; try rephrasing your request", strings.Join(categories, ", "))
+		}
+		return errors.New("the request was blocked by the provider's content filter; try rephrasing your request")
+	}
+
+	return fmt.Errorf("LLM API request failed with status %d: %s", status, apiErr.Error.Message)
+}
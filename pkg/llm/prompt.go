@@ -0,0 +1,98 @@
+package llm
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// defaultPromptTemplate is the built-in system prompt.
+//
+//go:embed system.tmpl
+var defaultPromptTemplate string
+
+// PromptData is what the system prompt template can refer to.
+type PromptData struct {
+	// Containers lists every container as "name (status)".
+	Containers []string
+	// DockerVersion is the daemon version, or empty if unknown.
+	DockerVersion string
+	// Plugins lists the installed CLI plugins, such as "compose" or
+	// "scout". It is empty if they couldn't be listed.
+	Plugins []string
+}
+
+// ContainerNames returns the names of Containers without their statuses.
+func (d PromptData) ContainerNames() []string {
+	names := make([]string, 0, len(d.Containers))
+	for _, entry := range d.Containers {
+		name, _, _ := strings.Cut(entry, " (")
+		names = append(names, name)
+	}
+	return names
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"has":  func(list []string, item string) bool { return slices.Contains(list, item) },
+	// untrusted fences data from Docker; see untrustedOpen.
+	"untrusted": untrusted,
+}
+
+// PromptTemplate is the system prompt: the built-in template plus any
+// override files.
+type PromptTemplate struct {
+	tmpl *template.Template
+	// Sources lists the override files that were applied, in order.
+	Sources []string
+}
+
+// LoadPromptTemplate parses the built-in template and then each of paths
+// that exists, in order. A file with top-level text replaces the prompt;
+// a file that only defines blocks ("format", "rules", "examples",
+// "context" or "extra") replaces just those, so later files refine
+// earlier ones.
+func LoadPromptTemplate(paths ...string) (*PromptTemplate, error) {
+	tmpl := template.Must(template.New("system").Funcs(promptFuncs).Parse(defaultPromptTemplate))
+
+	p := &PromptTemplate{tmpl: tmpl}
+	for _, path := range paths {
+		text, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.Parse(string(text)); err != nil {
+			return nil, fmt.Errorf("invalid prompt template %s: %w", path, err)
+		}
+		p.Sources = append(p.Sources, path)
+	}
+	return p, nil
+}
+
+// Render executes the template with data.
+func (p *PromptTemplate) Render(data PromptData) (string, error) {
+	var b strings.Builder
+	if err := p.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("could not render the system prompt: %w", err)
+	}
+	return strings.TrimSpace(b.String()) + "\n", nil
+}
+
+// defaultSystemPrompt renders the built-in template without any context,
+// for callers that don't supply a system prompt of their own.
+func defaultSystemPrompt() string {
+	p, _ := LoadPromptTemplate()
+	prompt, err := p.Render(PromptData{})
+	if err != nil {
+		panic(err)
+	}
+	return prompt
+}
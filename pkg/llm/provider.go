@@ -0,0 +1,235 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request is a single completion request sent to a provider.
+type Request struct {
+	Model        string
+	SystemPrompt string
+	// History holds earlier turns of the conversation, oldest first.
+	History []Message
+	Prompt  string
+	// JSON asks for a reply matching the Reply schema, using the provider's
+	// JSON mode or response schema where it has one.
+	JSON bool
+	// Tools are the functions the model may call instead of answering.
+	Tools []ToolDef
+	// ToolMessages are the tool calls made in answer to Prompt and their
+	// results, in order.
+	ToolMessages []Message
+	// Containers are the names of the containers the system prompt lists.
+	// Real models read them from the prompt; the mock provider, which
+	// doesn't, takes them from here.
+	Containers []string
+}
+
+// chatMessages returns the history, prompt and tool exchanges of req in the
+// OpenAI chat format, which the Ollama and Anthropic APIs share for plain
+// messages. withSystem puts the system prompt first, for APIs that take it
+// as a message rather than a separate field.
+func chatMessages(req Request, withSystem bool) []map[string]interface{} {
+	messages := make([]map[string]interface{}, 0, len(req.History)+len(req.ToolMessages)+2)
+	if withSystem {
+		messages = append(messages, map[string]interface{}{"role": "system", "content": req.SystemPrompt})
+	}
+	for _, m := range req.History {
+		messages = append(messages, map[string]interface{}{"role": m.Role, "content": m.Content})
+	}
+	messages = append(messages, map[string]interface{}{"role": RoleUser, "content": req.Prompt})
+
+	for _, m := range req.ToolMessages {
+		switch m.Role {
+		case RoleTool:
+			messages = append(messages, map[string]interface{}{
+				"role":         RoleTool,
+				"tool_call_id": m.ToolCallID,
+				"content":      m.Content,
+			})
+		default:
+			calls := make([]map[string]interface{}, len(m.ToolCalls))
+			for i, call := range m.ToolCalls {
+				arguments, _ := json.Marshal(call.Args)
+				calls[i] = map[string]interface{}{
+					"id":   call.ID,
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      call.Name,
+						"arguments": string(arguments),
+					},
+				}
+			}
+			messages = append(messages, map[string]interface{}{
+				"role":       RoleAssistant,
+				"content":    m.Content,
+				"tool_calls": calls,
+			})
+		}
+	}
+	return messages
+}
+
+// Response is the provider's reply to a Request.
+type Response struct {
+	Content string
+	// Provider and Model record who produced the reply. QueryLLM fills them
+	// in if Provider is empty. An empty Model means the provider's default.
+	Provider string
+	Model    string
+	// ToolCalls are the tools the model wants called before it answers.
+	ToolCalls []ToolCall
+	// Usage is nil if the provider didn't report token counts.
+	Usage *Usage
+}
+
+// Usage is the number of tokens a single call consumed.
+type Usage struct {
+	// Model is the model that served the call, as the provider names it.
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Capabilities describes the optional features a provider supports.
+type Capabilities struct {
+	Streaming   bool
+	JSONMode    bool
+	ToolCalling bool
+}
+
+// Options holds provider settings that come from flags or the config file.
+type Options struct {
+	// BaseURL overrides the provider's default endpoint.
+	BaseURL string
+	// AuthHeader is the header that carries the API key, e.g. "Authorization".
+	AuthHeader string
+	// AuthScheme prefixes the API key in AuthHeader, e.g. "Bearer". Set it to
+	// "none" to send the bare key.
+	AuthScheme string
+	// APIKeyEnv names the environment variable that holds the API key.
+	APIKeyEnv string
+	// Deployment is the Azure OpenAI deployment to send requests to.
+	Deployment string
+	// APIVersion is the Azure OpenAI api-version query parameter.
+	APIVersion string
+	// Timeout bounds each HTTP request to the provider, including reading
+	// the reply. Zero means no limit.
+	Timeout time.Duration
+	// RulesFile is the mock provider's rules file.
+	RulesFile string
+	// Transport is the HTTP transport to use instead of
+	// http.DefaultTransport; see NewTransport.
+	Transport http.RoundTripper
+	// Headers are added to every request, replacing any the provider sets.
+	Headers map[string]string
+	// WrapTransport, if set, wraps the HTTP transport the provider would
+	// otherwise use, e.g. to record or replay its traffic.
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+}
+
+// DefaultTimeout is the request timeout used when none is configured.
+const DefaultTimeout = 60 * time.Second
+
+// newHTTPClient builds the HTTP client a provider uses for its requests.
+func newHTTPClient(opts Options) *http.Client {
+	transport := opts.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	// Extra headers are added inside WrapTransport, so a cassette doesn't
+	// record them: they often carry credentials.
+	if len(opts.Headers) > 0 {
+		transport = &headerTransport{base: transport, headers: opts.Headers}
+	}
+	if opts.WrapTransport != nil {
+		transport = opts.WrapTransport(transport)
+	}
+	return &http.Client{Timeout: opts.Timeout, Transport: transport}
+}
+
+// Provider is an LLM backend that can turn a Request into a Response.
+type Provider interface {
+	// Name returns the name the provider is registered under.
+	Name() string
+	// Complete sends the request to the backend and returns its reply.
+	Complete(ctx context.Context, req Request) (*Response, error)
+	// Capabilities reports which optional features the backend supports.
+	Capabilities() Capabilities
+	// ValidateCredentials checks that the credentials the backend needs are
+	// present, without making a network call.
+	ValidateCredentials() error
+}
+
+// Streamer is implemented by providers that can deliver a reply
+// incrementally. Stream calls onChunk with each piece of text as it arrives
+// and returns the complete Response once the reply has finished.
+type Streamer interface {
+	Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error)
+}
+
+// completeAsStream answers a Stream call with complete, for wrappers whose
+// inner provider can't stream: the whole reply is one chunk.
+func completeAsStream(ctx context.Context, req Request, onChunk func(string), complete func(context.Context, Request) (*Response, error)) (*Response, error) {
+	resp, err := complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	onChunk(resp.Content)
+	return resp, nil
+}
+
+// Factory builds a Provider from the user's options.
+type Factory func(opts Options) Provider
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a provider available under the given name. It panics if the
+// name is empty or already registered, since that is a programming error.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" || factory == nil {
+		panic("llm: Register called with an empty name or nil factory")
+	}
+	if _, exists := registry[name]; exists {
+		panic("llm: provider registered twice: " + name)
+	}
+	registry[name] = factory
+}
+
+// Providers returns the names of all registered providers, sorted.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProvider looks up a registered provider by name and builds it.
+func NewProvider(name string, opts Options) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported LLM provider: %s (available: %s)", name, strings.Join(Providers(), ", "))
+	}
+	return factory(opts), nil
+}
@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit is a provider's quota. Zero means no limit.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// RateLimiter keeps calls within per-provider and per-model quotas, such as
+// the requests-per-minute and tokens-per-minute limits of free tiers. A call
+// that would exceed a quota waits until it fits instead of failing, and
+// waiting calls are served in the order they arrived. One RateLimiter can be
+// shared by every provider, and it is safe for concurrent use.
+type RateLimiter struct {
+	limits map[string]RateLimit
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*quota
+}
+
+// quota holds the buckets for one limits entry. A nil bucket is unlimited.
+type quota struct {
+	requests *tokenBucket
+	tokens   *tokenBucket
+}
+
+// NewRateLimiter returns a RateLimiter for limits, keyed by provider name
+// ("groq") or by provider and model ("groq/llama-3.1-8b-instant"). A model
+// entry wins over its provider's entry; calls with neither are not limited.
+func NewRateLimiter(limits map[string]RateLimit) *RateLimiter {
+	return &RateLimiter{limits: limits, now: time.Now, buckets: map[string]*quota{}}
+}
+
+// quota returns the buckets that apply to a call, or nil if it is not
+// limited. The caller must hold l.mu.
+func (l *RateLimiter) quota(provider, model string) *quota {
+	key := provider + "/" + model
+	limit, ok := l.limits[key]
+	if !ok || model == "" {
+		key = provider
+		if limit, ok = l.limits[key]; !ok {
+			return nil
+		}
+	}
+	if q := l.buckets[key]; q != nil {
+		return q
+	}
+	q := &quota{
+		requests: newTokenBucket(limit.RequestsPerMinute, l.now()),
+		tokens:   newTokenBucket(limit.TokensPerMinute, l.now()),
+	}
+	l.buckets[key] = q
+	return q
+}
+
+// reserve takes one request and tokens tokens from the quota and returns
+// how long the caller must wait before using them.
+func (l *RateLimiter) reserve(provider, model string, tokens int) (*quota, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	q := l.quota(provider, model)
+	if q == nil {
+		return nil, 0
+	}
+	now := l.now()
+	return q, max(q.requests.take(1, now), q.tokens.take(float64(tokens), now))
+}
+
+// cancel returns a reservation that was not used.
+func (l *RateLimiter) cancel(q *quota, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	q.requests.give(1)
+	q.tokens.give(float64(tokens))
+}
+
+// settle corrects the estimated tokens of a call once its actual usage is
+// known.
+func (l *RateLimiter) settle(q *quota, estimated, actual int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	q.tokens.give(float64(estimated - actual))
+}
+
+// tokenBucket refills continuously up to one minute's quota. Its level can
+// go below zero: each call takes its share up front and waits until the
+// level would have recovered, so later callers queue behind earlier ones.
+type tokenBucket struct {
+	capacity  float64
+	perSecond float64
+	level     float64
+	updated   time.Time
+}
+
+// newTokenBucket returns a full bucket for perMinute, or nil if perMinute
+// is not a limit.
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity:  float64(perMinute),
+		perSecond: float64(perMinute) / 60,
+		level:     float64(perMinute),
+		updated:   now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.level = min(b.capacity, b.level+elapsed*b.perSecond)
+		b.updated = now
+	}
+}
+
+// delay returns how long until n could be taken without going below zero.
+// A call larger than the whole quota only waits for a full bucket.
+func (b *tokenBucket) delay(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.refill(now)
+	short := min(n, b.capacity) - b.level
+	if short <= 0 {
+		return 0
+	}
+	return time.Duration(short / b.perSecond * float64(time.Second))
+}
+
+// take removes n from the bucket and returns how long to wait before
+// using it.
+func (b *tokenBucket) take(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	wait := b.delay(n, now)
+	b.level -= min(n, b.capacity)
+	return wait
+}
+
+// give puts n back, or takes -n more if n is negative.
+func (b *tokenBucket) give(n float64) {
+	if b == nil {
+		return
+	}
+	b.level = min(b.capacity, b.level+n)
+}
+
+// rateLimited wraps a Provider so its calls go through a RateLimiter.
+type rateLimited struct {
+	Provider
+	limiter *RateLimiter
+	onWait  func(provider string, wait time.Duration)
+}
+
+// WithRateLimit returns a Provider whose calls wait for l before reaching p.
+// onWait, if set, is called before a call waits, so the wait can be shown.
+// Wrap each provider of a fallback chain separately, inside any retries, so
+// every attempt counts against the provider that makes it.
+func WithRateLimit(p Provider, l *RateLimiter, onWait func(provider string, wait time.Duration)) Provider {
+	return &rateLimited{Provider: p, limiter: l, onWait: onWait}
+}
+
+func (r *rateLimited) Complete(ctx context.Context, req Request) (*Response, error) {
+	return r.do(ctx, req, func() (*Response, error) {
+		return r.Provider.Complete(ctx, req)
+	})
+}
+
+func (r *rateLimited) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	streamer, ok := r.Provider.(Streamer)
+	if !ok {
+		return completeAsStream(ctx, req, onChunk, r.Complete)
+	}
+	return r.do(ctx, req, func() (*Response, error) {
+		return streamer.Stream(ctx, req, onChunk)
+	})
+}
+
+// do waits for the quota, makes the call, and then charges the tokens it
+// actually used if the provider reported them.
+func (r *rateLimited) do(ctx context.Context, req Request, call func() (*Response, error)) (*Response, error) {
+	estimated := requestTokens(req)
+	q, wait := r.limiter.reserve(r.Name(), req.Model, estimated)
+	if q == nil {
+		return call()
+	}
+	if wait > 0 {
+		if r.onWait != nil {
+			r.onWait(r.Name(), wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			r.limiter.cancel(q, estimated)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	resp, err := call()
+	if err == nil && resp.Usage != nil {
+		r.limiter.settle(q, estimated, resp.Usage.PromptTokens+resp.Usage.CompletionTokens)
+	}
+	return resp, err
+}
+
+// requestTokens estimates the prompt tokens of req, before the provider
+// reports the real count.
+func requestTokens(req Request) int {
+	messages := append([]Message{
+		{Content: req.SystemPrompt},
+		{Content: req.Prompt},
+	}, req.History...)
+	return estimateTokens(append(messages, req.ToolMessages...))
+}
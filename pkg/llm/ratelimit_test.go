@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock tests can move by hand. It is safe for concurrent
+// use, like the limiter that reads it.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestLimiter(limits map[string]RateLimit) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter(limits)
+	l.now = clock.now
+	return l, clock
+}
+
+// TestRateLimiterParallel reserves from many goroutines at the same instant.
+// However they interleave, each call must queue behind the ones before it:
+// with 60 requests a minute, the first 60 go at once and the rest one
+// second apart.
+func TestRateLimiterParallel(t *testing.T) {
+	l, _ := newTestLimiter(map[string]RateLimit{"groq": {RequestsPerMinute: 60}})
+
+	const calls = 100
+	waits := make([]time.Duration, calls)
+	var wg sync.WaitGroup
+	for i := range waits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, waits[i] = l.reserve("groq", "", 0)
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(waits)
+	for i, wait := range waits {
+		want := time.Duration(0)
+		if i >= 60 {
+			want = time.Duration(i-59) * time.Second
+		}
+		if d := wait - want; d < -time.Millisecond || d > time.Millisecond {
+			t.Fatalf("call %d waits %s, want %s", i, wait, want)
+		}
+	}
+}
+
+func TestRateLimiterRefillAndSettle(t *testing.T) {
+	l, clock := newTestLimiter(map[string]RateLimit{"groq": {TokensPerMinute: 6000}})
+
+	q, wait := l.reserve("groq", "", 6000)
+	if wait != 0 {
+		t.Fatalf("first call waits %s, want none", wait)
+	}
+	if _, wait = l.reserve("groq", "", 600); wait != 6*time.Second {
+		t.Fatalf("call over the quota waits %s, want 6s", wait)
+	}
+	l.cancel(q, 600)
+
+	// The first call used only 1000 of its 6000 tokens, so 5000 come back.
+	l.settle(q, 6000, 1000)
+	if _, wait = l.reserve("groq", "", 5000); wait != 0 {
+		t.Fatalf("call after settling waits %s, want none", wait)
+	}
+
+	clock.advance(30 * time.Second)
+	if _, wait = l.reserve("groq", "", 3000); wait != 0 {
+		t.Fatalf("call after refilling waits %s, want none", wait)
+	}
+}
+
+func TestRateLimiterKeys(t *testing.T) {
+	l, _ := newTestLimiter(map[string]RateLimit{
+		"groq":                      {RequestsPerMinute: 1},
+		"groq/llama-3.1-8b-instant": {RequestsPerMinute: 100},
+	})
+
+	if q, _ := l.reserve("openai", "gpt-4o", 0); q != nil {
+		t.Error("a provider without limits was limited")
+	}
+	for i := 0; i < 50; i++ {
+		if _, wait := l.reserve("groq", "llama-3.1-8b-instant", 0); wait != 0 {
+			t.Fatalf("model limit not used: call %d waits %s", i+1, wait)
+		}
+	}
+	l.reserve("groq", "llama-3.3-70b-versatile", 0)
+	if _, wait := l.reserve("groq", "", 0); wait == 0 {
+		t.Error("models without their own limit don't share the provider's")
+	}
+}
+
+// countingProvider answers every call and counts them.
+type countingProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *countingProvider) Name() string               { return "groq" }
+func (p *countingProvider) Capabilities() Capabilities { return Capabilities{} }
+func (p *countingProvider) ValidateCredentials() error { return nil }
+
+func (p *countingProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return &Response{Content: "docker ps", Usage: &Usage{PromptTokens: 10, CompletionTokens: 5}}, nil
+}
+
+func TestWithRateLimitCancel(t *testing.T) {
+	l, _ := newTestLimiter(map[string]RateLimit{"groq": {RequestsPerMinute: 1}})
+	inner := &countingProvider{}
+	var waited []time.Duration
+	p := WithRateLimit(inner, l, func(provider string, wait time.Duration) {
+		waited = append(waited, wait)
+	})
+
+	if _, err := p.Complete(context.Background(), Request{Prompt: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Complete(ctx, Request{Prompt: "hi"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if inner.calls != 1 {
+		t.Errorf("provider called %d times, want 1", inner.calls)
+	}
+	if len(waited) != 1 || waited[0] != time.Minute {
+		t.Errorf("onWait got %v, want one wait of 1m", waited)
+	}
+
+	// The cancelled call gave its request back, so the next one waits no
+	// longer than the second call did.
+	if _, wait := l.reserve("groq", "", 0); wait != time.Minute {
+		t.Errorf("next call waits %s, want 1m", wait)
+	}
+}
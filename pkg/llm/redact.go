@@ -0,0 +1,298 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DefaultRedactPatterns detect common secrets. If a pattern has a capture
+// group, only the group is redacted, so "DB_PASSWORD=hunter2" keeps its
+// name and the model still knows what the value was.
+var DefaultRedactPatterns = []string{
+	// AWS access key IDs and secret keys.
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	`(?i)aws_secret_access_key\s*[=:]\s*"?([A-Za-z0-9/+=]{40})`,
+	// JSON web tokens.
+	`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+	// GitHub tokens.
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	// Environment pairs such as PASSWORD=..., API_TOKEN="...". The name has
+	// to end in the keyword (or a number after it), so settings such as
+	// max_tokens=1024 are left alone.
+	`(?i)\b[A-Z0-9_]*(?:PASSWORD|PASSWD|SECRET(?:_?KEY)?|TOKEN|API_?KEY|ACCESS_KEY|PRIVATE_KEY)(?:_?[0-9]+)?\s*=\s*("[^"]*"|'[^']*'|[^\s,;"']+)`,
+	// --password flags, as in docker login.
+	`--password[ =]("[^"]*"|'[^']*'|\S+)`,
+	// Passwords in URLs such as postgres://user:secret@db/app.
+	`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`,
+}
+
+// placeholderRegex matches the placeholders a Redactor substitutes.
+var placeholderRegex = regexp.MustCompile(`__REDACTED_\d+__`)
+
+const placeholderPrefix = "__REDACTED_"
+
+// Redactor replaces secrets with placeholders such as __REDACTED_1__. The
+// same secret always gets the same placeholder, so the model can refer to
+// it consistently across turns, and Restore swaps the real value back in.
+type Redactor struct {
+	patterns []*regexp.Regexp
+
+	mu           sync.Mutex
+	placeholders map[string]string // secret -> placeholder
+	secrets      map[string]string // placeholder -> secret
+}
+
+// NewRedactor returns a Redactor using DefaultRedactPatterns plus extra.
+func NewRedactor(extra []string) (*Redactor, error) {
+	r := &Redactor{placeholders: map[string]string{}, secrets: map[string]string{}}
+	for _, pattern := range append(append([]string(nil), DefaultRedactPatterns...), extra...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Redact replaces every secret in text with its placeholder.
+func (r *Redactor) Redact(text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, re := range r.patterns {
+		var b strings.Builder
+		last := 0
+		for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			b.WriteString(text[last:start])
+			b.WriteString(r.placeholder(text[start:end]))
+			last = end
+		}
+		b.WriteString(text[last:])
+		text = b.String()
+	}
+	return text
+}
+
+// placeholder returns the placeholder for secret, assigning one if needed.
+// Text that is already a placeholder is left alone.
+func (r *Redactor) placeholder(secret string) string {
+	if placeholderRegex.MatchString(secret) {
+		return secret
+	}
+	if p, ok := r.placeholders[secret]; ok {
+		return p
+	}
+	p := fmt.Sprintf("%s%d__", placeholderPrefix, len(r.placeholders)+1)
+	r.placeholders[secret] = p
+	r.secrets[p] = secret
+	return p
+}
+
+// Restore replaces placeholders in text with their secrets. With
+// jsonEscaped set, the secrets are escaped for use inside a JSON string.
+func (r *Redactor) Restore(text string, jsonEscaped bool) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return placeholderRegex.ReplaceAllStringFunc(text, func(p string) string {
+		secret, ok := r.secrets[p]
+		if !ok {
+			return p
+		}
+		if jsonEscaped {
+			quoted, _ := json.Marshal(secret)
+			return string(quoted[1 : len(quoted)-1])
+		}
+		return secret
+	})
+}
+
+// restoreValue restores placeholders in the strings of a decoded JSON value.
+func (r *Redactor) restoreValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return r.Restore(v, false)
+	case map[string]interface{}:
+		restored := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			restored[key] = r.restoreValue(value)
+		}
+		return restored
+	case []interface{}:
+		restored := make([]interface{}, len(v))
+		for i, value := range v {
+			restored[i] = r.restoreValue(value)
+		}
+		return restored
+	default:
+		return v
+	}
+}
+
+// redactValue is the counterpart of restoreValue.
+func (r *Redactor) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return r.Redact(v)
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			redacted[key] = r.redactValue(value)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, value := range v {
+			redacted[i] = r.redactValue(value)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+// redacting wraps a Provider so that secrets never reach it.
+type redacting struct {
+	Provider
+	r *Redactor
+}
+
+// WithRedaction returns a Provider that redacts every text it sends to p,
+// including the system prompt, history and tool output, and restores the
+// placeholders in p's replies.
+func WithRedaction(p Provider, r *Redactor) Provider {
+	return &redacting{Provider: p, r: r}
+}
+
+func (d *redacting) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := d.Provider.Complete(ctx, d.redact(req))
+	if err != nil {
+		return nil, err
+	}
+	return d.restore(resp), nil
+}
+
+func (d *redacting) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	streamer, ok := d.Provider.(Streamer)
+	if !ok {
+		return completeAsStream(ctx, req, onChunk, d.Complete)
+	}
+
+	w := &restoringWriter{r: d.r, onChunk: onChunk}
+	resp, err := streamer.Stream(ctx, d.redact(req), w.write)
+	w.flush()
+	if err != nil {
+		return nil, err
+	}
+	return d.restore(resp), nil
+}
+
+func (d *redacting) redact(req Request) Request {
+	req.SystemPrompt = d.r.Redact(req.SystemPrompt)
+	req.Prompt = d.r.Redact(req.Prompt)
+	req.History = d.redactMessages(req.History)
+	req.ToolMessages = d.redactMessages(req.ToolMessages)
+	return req
+}
+
+func (d *redacting) redactMessages(messages []Message) []Message {
+	if messages == nil {
+		return nil
+	}
+	redacted := make([]Message, len(messages))
+	for i, m := range messages {
+		m.Content = d.r.Redact(m.Content)
+		if m.ToolCalls != nil {
+			calls := make([]ToolCall, len(m.ToolCalls))
+			for j, call := range m.ToolCalls {
+				call.Args, _ = d.r.redactValue(call.Args).(map[string]interface{})
+				calls[j] = call
+			}
+			m.ToolCalls = calls
+		}
+		redacted[i] = m
+	}
+	return redacted
+}
+
+func (d *redacting) restore(resp *Response) *Response {
+	restored := *resp
+	restored.Content = d.r.Restore(resp.Content, isJSONText(resp.Content))
+	if resp.ToolCalls != nil {
+		restored.ToolCalls = make([]ToolCall, len(resp.ToolCalls))
+		for i, call := range resp.ToolCalls {
+			call.Args, _ = d.r.restoreValue(call.Args).(map[string]interface{})
+			restored.ToolCalls[i] = call
+		}
+	}
+	return &restored
+}
+
+// isJSONText reports whether text looks like a JSON reply, so restored
+// secrets must be escaped to keep it valid.
+func isJSONText(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "{")
+}
+
+// restoringWriter restores placeholders in a stream. A placeholder can be
+// split across chunks, so text that might be the start of one is held back
+// until the next chunk shows whether it is.
+type restoringWriter struct {
+	r       *Redactor
+	onChunk func(string)
+	pending string
+	// isJSON is decided by the first non-space text of the stream.
+	isJSON  bool
+	decided bool
+}
+
+func (w *restoringWriter) write(chunk string) {
+	w.pending += chunk
+	if !w.decided && strings.TrimSpace(w.pending) != "" {
+		w.isJSON, w.decided = isJSONText(w.pending), true
+	}
+	held := partialPlaceholder(w.pending)
+	if ready := w.pending[:len(w.pending)-held]; ready != "" {
+		w.onChunk(w.r.Restore(ready, w.isJSON))
+	}
+	w.pending = w.pending[len(w.pending)-held:]
+}
+
+func (w *restoringWriter) flush() {
+	if w.pending != "" {
+		w.onChunk(w.r.Restore(w.pending, w.isJSON))
+		w.pending = ""
+	}
+}
+
+// partialPlaceholderRegex matches an incomplete placeholder at the end of
+// the text.
+var partialPlaceholderRegex = regexp.MustCompile(`__REDACTED_\d*_?$`)
+
+// partialPlaceholder returns the length of the suffix of text that could be
+// the start of a placeholder. The closing "__" of a complete placeholder
+// looks like the start of another one, so it is never held back.
+func partialPlaceholder(text string) int {
+	held := 0
+	if loc := partialPlaceholderRegex.FindStringIndex(text); loc != nil {
+		held = len(text) - loc[0]
+	} else {
+		for n := len(placeholderPrefix) - 1; n > 0; n-- {
+			if strings.HasSuffix(text, placeholderPrefix[:n]) {
+				held = n
+				break
+			}
+		}
+	}
+	if locs := placeholderRegex.FindAllStringIndex(text, -1); len(locs) > 0 {
+		held = min(held, len(text)-locs[len(locs)-1][1])
+	}
+	return held
+}
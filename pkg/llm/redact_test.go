@@ -0,0 +1,246 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedactEnvPairs(t *testing.T) {
+	tests := []struct {
+		text   string
+		secret string // empty if nothing should be redacted
+	}{
+		{text: "DB_PASSWORD=hunter2", secret: "hunter2"},
+		{text: `GITHUB_TOKEN="abc123"`, secret: `"abc123"`},
+		{text: "api_key = sk-live-1", secret: "sk-live-1"},
+		{text: "DJANGO_SECRET_KEY=s3cr3t", secret: "s3cr3t"},
+		{text: "AWS_SECRET_ACCESS_KEY=wJalr", secret: "wJalr"},
+		{text: "OAUTH_CLIENT_SECRET=xyz", secret: "xyz"},
+		{text: "API_TOKEN_2=second", secret: "second"},
+		{text: "max_tokens=1024"},
+		{text: "MAX_TOKENS=4096"},
+		{text: "PASSWORD_FILE=/run/secrets/db"},
+		{text: "TOKENIZER=cl100k"},
+		{text: "secrets=3"},
+	}
+	for _, tt := range tests {
+		r, err := NewRedactor(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := r.Redact(tt.text)
+		if tt.secret == "" {
+			if got != tt.text {
+				t.Errorf("Redact(%q) = %q, want it unchanged", tt.text, got)
+			}
+			continue
+		}
+		if strings.Contains(got, tt.secret) || !placeholderRegex.MatchString(got) {
+			t.Errorf("Redact(%q) = %q, want %q replaced", tt.text, got, tt.secret)
+		}
+	}
+}
+
+// newTestRedactor returns a Redactor that has already replaced secret, which
+// needs escaping in JSON, with __REDACTED_1__.
+func newTestRedactor(t *testing.T) *Redactor {
+	t.Helper()
+	r, err := NewRedactor([]string{`pass:(\S+)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Redact("pass:" + testSecret); got != "pass:__REDACTED_1__" {
+		t.Fatalf("Redact = %q, want pass:__REDACTED_1__", got)
+	}
+	return r
+}
+
+const testSecret = `pa\"ss`
+
+func TestRedactorRestore(t *testing.T) {
+	tests := []struct {
+		text        string
+		jsonEscaped bool
+		want        string
+	}{
+		{text: "docker login -p __REDACTED_1__", want: `docker login -p pa\"ss`},
+		{text: `{"command":"docker login -p __REDACTED_1__"}`, jsonEscaped: true, want: `{"command":"docker login -p pa\\\"ss"}`},
+		{text: "__REDACTED_1____REDACTED_1__", want: testSecret + testSecret},
+		{text: "docker ps __REDACTED_2__", want: "docker ps __REDACTED_2__"},
+		{text: "docker ps __REDACTED_1_", want: "docker ps __REDACTED_1_"},
+		{text: "docker ps", want: "docker ps"},
+	}
+	r := newTestRedactor(t)
+	for _, tt := range tests {
+		if got := r.Restore(tt.text, tt.jsonEscaped); got != tt.want {
+			t.Errorf("Restore(%q, %v) = %q, want %q", tt.text, tt.jsonEscaped, got, tt.want)
+		}
+	}
+
+	var reply Reply
+	if err := json.Unmarshal([]byte(r.Restore(`{"command":"__REDACTED_1__"}`, true)), &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Command != testSecret {
+		t.Errorf("restored JSON decodes to %q, want %q", reply.Command, testSecret)
+	}
+}
+
+func TestPartialPlaceholder(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "docker ps", want: 0},
+		{text: "docker ps _", want: 1},
+		{text: "docker ps __RED", want: 5},
+		{text: "docker ps __REDACTED_", want: 11},
+		{text: "docker ps __REDACTED_12", want: 13},
+		{text: "docker ps __REDACTED_12_", want: 14},
+		{text: "docker ps __REDACTED_1__", want: 0},
+		{text: "docker ps __REDACTED_1____RE", want: 4},
+		{text: "__REDACTED_1__ done", want: 0},
+	}
+	for _, tt := range tests {
+		if got := partialPlaceholder(tt.text); got != tt.want {
+			t.Errorf("partialPlaceholder(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestRestoringWriter streams text split at every possible point, so that
+// each placeholder is cut in two at some point, and checks that what comes
+// out is the whole restored text.
+func TestRestoringWriter(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: `{"command":"docker login -p __REDACTED_1__"}`, want: `{"command":"docker login -p pa\\\"ss"}`},
+		{text: "Log in with docker login -p __REDACTED_1__ first.", want: `Log in with docker login -p pa\"ss first.`},
+		{text: "__REDACTED_1__", want: testSecret},
+		{text: "keep __REDACTED_2__ and __REDACTED_", want: "keep __REDACTED_2__ and __REDACTED_"},
+	}
+	r := newTestRedactor(t)
+	for _, tt := range tests {
+		for i := 0; i <= len(tt.text); i++ {
+			for _, chunks := range [][]string{
+				{tt.text[:i], tt.text[i:]},
+				strings.Split(tt.text, ""),
+			} {
+				var out strings.Builder
+				w := &restoringWriter{r: r, onChunk: func(s string) { out.WriteString(s) }}
+				for _, chunk := range chunks {
+					w.write(chunk)
+				}
+				w.flush()
+				if out.String() != tt.want {
+					t.Fatalf("chunks %q restored to %q, want %q", chunks, out.String(), tt.want)
+				}
+			}
+		}
+	}
+}
+
+// echoProvider answers with a login command for the first placeholder in
+// the prompt, and records the request it got. Streaming sends the reply a
+// few bytes at a time.
+type echoProvider struct {
+	req Request
+}
+
+func (p *echoProvider) Name() string               { return "echo" }
+func (p *echoProvider) Capabilities() Capabilities { return Capabilities{Streaming: true} }
+func (p *echoProvider) ValidateCredentials() error { return nil }
+
+func (p *echoProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	p.req = req
+	placeholder := placeholderRegex.FindString(req.Prompt)
+	return &Response{
+		Content:   `{"risk_level":"medium","command":"docker login -p ` + placeholder + `"}`,
+		ToolCalls: []ToolCall{{Name: "inspect", Args: map[string]interface{}{"env": []interface{}{"PASS=" + placeholder}}}},
+	}, nil
+}
+
+func (p *echoProvider) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	resp, err := p.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for text := resp.Content; text != ""; {
+		n := min(3, len(text))
+		onChunk(text[:n])
+		text = text[n:]
+	}
+	return resp, nil
+}
+
+// completeOnly hides the Stream method of the provider it wraps.
+type completeOnly struct{ Provider }
+
+func TestWithRedaction(t *testing.T) {
+	calls := []struct {
+		name   string
+		stream bool
+		inner  func(*echoProvider) Provider
+	}{
+		{name: "complete", inner: func(p *echoProvider) Provider { return p }},
+		{name: "stream", stream: true, inner: func(p *echoProvider) Provider { return p }},
+		{name: "stream without a streamer", stream: true, inner: func(p *echoProvider) Provider { return completeOnly{p} }},
+	}
+	for _, call := range calls {
+		t.Run(call.name, func(t *testing.T) {
+			echo := &echoProvider{}
+			p := WithRedaction(call.inner(echo), newTestRedactor(t))
+			req := Request{
+				SystemPrompt: "Containers: web (DB_PASSWORD=hunter2)",
+				History:      []Message{{Role: RoleUser, Content: "I use pass:" + testSecret}},
+				Prompt:       "log in with pass:" + testSecret,
+				ToolMessages: []Message{
+					{Role: RoleAssistant, ToolCalls: []ToolCall{{Name: "inspect", Args: map[string]interface{}{"filter": "pass:" + testSecret}}}},
+					{Role: RoleTool, Content: "API_TOKEN=tok123"},
+				},
+			}
+
+			var resp *Response
+			var streamed strings.Builder
+			var err error
+			if call.stream {
+				resp, err = p.(Streamer).Stream(context.Background(), req, func(s string) { streamed.WriteString(s) })
+			} else {
+				resp, err = p.Complete(context.Background(), req)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sent := fmt.Sprintf("%+v", echo.req)
+			for _, secret := range []string{testSecret, "hunter2", "tok123"} {
+				if strings.Contains(sent, secret) {
+					t.Errorf("the provider was sent %q: %s", secret, sent)
+				}
+			}
+
+			want := `{"risk_level":"medium","command":"docker login -p pa\\\"ss"}`
+			if resp.Content != want {
+				t.Errorf("Content = %q, want %q", resp.Content, want)
+			}
+			if call.stream && streamed.String() != want {
+				t.Errorf("streamed %q, want %q", streamed.String(), want)
+			}
+			reply, err := ParseReply(resp.Content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reply.Command != `docker login -p pa\"ss` {
+				t.Errorf("Command = %q", reply.Command)
+			}
+			if env := resp.ToolCalls[0].Args["env"].([]interface{})[0]; env != "PASS="+testSecret {
+				t.Errorf("tool call argument = %q, want the secret restored", env)
+			}
+		})
+	}
+}
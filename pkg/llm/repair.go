@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxRepairStderr caps how much of a failed command's stderr is sent back to
+// the model.
+const maxRepairStderr = 2000
+
+// FailedCommand is a generated command that exited with an error.
+type FailedCommand struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+}
+
+// RepairPrompt asks the model to correct a failed command. failures lists
+// every attempt so far, oldest first, so the model doesn't suggest a command
+// that has already failed. request is the user's original request.
+func RepairPrompt(request string, failures []FailedCommand) string {
+	var b strings.Builder
+	b.WriteString(request)
+	b.WriteString("\n\nThe following commands were run for this request and failed:\n")
+	for i, f := range failures {
+		stderr := strings.TrimSpace(f.Stderr)
+		if len(stderr) > maxRepairStderr {
+			stderr = "..." + stderr[len(stderr)-maxRepairStderr:]
+		}
+		fmt.Fprintf(&b, "\n%d. `%s` exited with status %d.\nstderr:\n%s\n", i+1, f.Command, f.ExitCode, fenceUntrusted(stderr))
+	}
+	b.WriteString("\nReply with a corrected command that fulfils the original request. Do not repeat a command that already failed. If the error can't be fixed with a different Docker command, leave \"command\" empty and explain why.")
+	return b.String()
+}
@@ -0,0 +1,225 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Risk levels a Reply can report.
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// Reply is the structured answer the model is asked to return.
+type Reply struct {
+	// Command is the Docker command to run, or empty if there is nothing to
+	// run.
+	Command string `json:"command"`
+	// Explanation is a short description of the command, or the whole answer
+	// when there is no command.
+	Explanation string `json:"explanation"`
+	// RiskLevel is one of RiskLow, RiskMedium or RiskHigh. Validate rates a
+	// reply without a known level RiskHigh.
+	RiskLevel string `json:"risk_level"`
+	// NeedsClarification is set when the request is ambiguous; Question then
+	// holds what to ask the user.
+	NeedsClarification bool   `json:"needs_clarification"`
+	Question           string `json:"question"`
+	// Candidates, when Query.Candidates asks for them, lists the reasonable
+	// commands for the request, best first. The first one repeats Command.
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// Candidate is one of several commands that could serve a request.
+type Candidate struct {
+	Command     string `json:"command"`
+	Explanation string `json:"explanation"`
+	RiskLevel   string `json:"risk_level"`
+}
+
+// replyFields lists the Reply fields in the order the model should emit
+// them. Prose comes first so it can be streamed before the command arrives.
+var replyFields = []string{"needs_clarification", "question", "explanation", "risk_level", "command"}
+
+// replySchema is the JSON schema of Reply, for providers that accept one.
+var replySchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"needs_clarification": map[string]interface{}{"type": "boolean"},
+		"question":            map[string]interface{}{"type": "string"},
+		"explanation":         map[string]interface{}{"type": "string"},
+		"risk_level":          map[string]interface{}{"type": "string", "enum": []string{RiskLow, RiskMedium, RiskHigh}},
+		"command":             map[string]interface{}{"type": "string"},
+		"candidates": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"command":     map[string]interface{}{"type": "string"},
+					"explanation": map[string]interface{}{"type": "string"},
+					"risk_level":  map[string]interface{}{"type": "string", "enum": []string{RiskLow, RiskMedium, RiskHigh}},
+				},
+				"required":             []string{"command", "explanation", "risk_level"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             replyFields,
+	"additionalProperties": false,
+}
+
+// Validate checks that the reply is internally consistent and that any
+// command runs nothing but Docker; see checkCommand. A command that writes
+// to a file is rated high risk, so it is confirmed before it runs.
+func (r *Reply) Validate() error {
+	r.Command = strings.TrimSpace(r.Command)
+	r.Explanation = strings.TrimSpace(r.Explanation)
+	r.Question = strings.TrimSpace(r.Question)
+	r.RiskLevel = normalizeRiskLevel(r.RiskLevel)
+
+	if r.NeedsClarification {
+		if r.Question == "" {
+			return errors.New("needs_clarification is set but question is empty")
+		}
+		// Never run anything while the request is still ambiguous.
+		r.Command = ""
+		r.Candidates = nil
+		return nil
+	}
+
+	if r.Command != "" {
+		writesFile, err := checkCommand(r.Command)
+		if err != nil {
+			return err
+		}
+		if writesFile {
+			r.RiskLevel = RiskHigh
+		}
+	}
+	if err := r.validateCandidates(); err != nil {
+		return err
+	}
+
+	if r.Command == "" && r.Explanation == "" {
+		return errors.New("reply has no command, explanation or question")
+	}
+	return nil
+}
+
+// validateCandidates checks every candidate and makes the first one match
+// Command, filling Command in from the candidates if the model left it
+// empty.
+func (r *Reply) validateCandidates() error {
+	if len(r.Candidates) == 0 {
+		return nil
+	}
+	var candidates []Candidate
+	if r.Command != "" {
+		candidates = append(candidates, Candidate{Command: r.Command, Explanation: r.Explanation, RiskLevel: r.RiskLevel})
+	}
+	for i, c := range r.Candidates {
+		c.Command = strings.TrimSpace(c.Command)
+		c.Explanation = strings.TrimSpace(c.Explanation)
+		c.RiskLevel = normalizeRiskLevel(c.RiskLevel)
+		writesFile, err := checkCommand(c.Command)
+		if err != nil {
+			return fmt.Errorf("candidate %d: %w", i+1, err)
+		}
+		if writesFile {
+			c.RiskLevel = RiskHigh
+		}
+		if !slices.ContainsFunc(candidates, func(seen Candidate) bool { return seen.Command == c.Command }) {
+			candidates = append(candidates, c)
+		}
+	}
+	r.Candidates = candidates
+	if r.Command == "" {
+		r.Command, r.Explanation, r.RiskLevel = candidates[0].Command, candidates[0].Explanation, candidates[0].RiskLevel
+	}
+	return nil
+}
+
+// normalizeRiskLevel returns level in lower case. A missing level, or one
+// the model made up such as "moderate", is rated high so that the command
+// is confirmed: JSON mode without a schema doesn't hold the model to the
+// enum.
+func normalizeRiskLevel(level string) string {
+	switch level = strings.ToLower(strings.TrimSpace(level)); level {
+	case RiskLow, RiskMedium, RiskHigh:
+		return level
+	default:
+		return RiskHigh
+	}
+}
+
+// dockerCommandRegex matches commands that start with the docker CLI or the
+// standalone docker-compose binary.
+var dockerCommandRegex = regexp.MustCompile(`^docker(-compose)?(\s|$)`)
+
+func isDockerCommand(command string) bool {
+	return dockerCommandRegex.MatchString(command)
+}
+
+// ParseReply decodes the model's answer into a Reply. A JSON command
+// wrapped in backticks or a "$ " prompt is cleaned up just like a
+// plain-text answer; see normalizeCommand. Models that
+// ignore the JSON instructions are handled too: the first command found in
+// the text becomes Command, alternatives become Candidates, and text
+// without a command becomes Explanation.
+func ParseReply(text string) (*Reply, error) {
+	text = strings.TrimSpace(text)
+
+	if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start >= 0 && end > start {
+		var reply Reply
+		if err := json.Unmarshal([]byte(text[start:end+1]), &reply); err == nil {
+			reply.Command = normalizeCommand(reply.Command)
+			for i := range reply.Candidates {
+				reply.Candidates[i].Command = normalizeCommand(reply.Candidates[i].Command)
+			}
+			if err := reply.Validate(); err != nil {
+				return nil, fmt.Errorf("the model returned an invalid reply: %w", err)
+			}
+			return &reply, nil
+		}
+	}
+
+	if commands := ExtractDockerCommands(text); len(commands) > 0 {
+		reply := &Reply{Command: commands[0]}
+		if len(commands) > 1 {
+			for _, command := range commands {
+				reply.Candidates = append(reply.Candidates, Candidate{Command: command})
+			}
+		}
+		return reply, reply.Validate()
+	}
+
+	// Clean up the response to remove markdown and extra quotes
+	text = regexp.MustCompile("`{3}(bash|sh)?").ReplaceAllString(text, "")
+	text = strings.Trim(text, "`\n ")
+	if text == "" {
+		return nil, errors.New("the model returned an empty reply")
+	}
+	return &Reply{Explanation: text}, nil
+}
+
+// normalizeCommand cleans up the command of a JSON reply. Only a command
+// wrapped in backquotes or a code block, or shown after a shell prompt, is
+// taken apart line by line, and several commands in it are run one after
+// the other. Anything else is kept as is, since a command such as
+// sh -c 'echo a<newline>echo b' can span lines inside its quotes.
+func normalizeCommand(command string) string {
+	command = strings.TrimSpace(command)
+	if !strings.HasPrefix(command, "`") && !strings.HasPrefix(command, "~~~") && !promptRegex.MatchString(command) {
+		return command
+	}
+	if commands := ExtractDockerCommands(command); len(commands) > 0 {
+		return strings.Join(commands, " && ")
+	}
+	return command
+}
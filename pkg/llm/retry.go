@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// StatusError is returned when a provider answers with a non-200 status.
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is how long the provider asked us to wait, if it said.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *StatusError) Error() string { return e.Err.Error() }
+
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// newStatusError wraps err with the status and Retry-After of resp.
+func newStatusError(resp *http.Response, err error) error {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Err:        err,
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil && when.After(now) {
+		return when.Sub(now)
+	}
+	return 0
+}
+
+// RetryPolicy controls how failed provider calls are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles each time.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff, but not a provider's Retry-After.
+	MaxDelay time.Duration
+	// OnRetry, if set, is called before each retry with the attempt that is
+	// about to start.
+	OnRetry func(attempt int, wait time.Duration, err error)
+}
+
+// DefaultRetryPolicy is used when no policy is configured.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// retrying wraps a Provider and retries rate limits, server errors and
+// transient network failures.
+type retrying struct {
+	Provider
+	policy RetryPolicy
+}
+
+// WithRetry returns a Provider that retries p's calls according to policy.
+func WithRetry(p Provider, policy RetryPolicy) Provider {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	return &retrying{Provider: p, policy: policy}
+}
+
+func (r *retrying) Complete(ctx context.Context, req Request) (*Response, error) {
+	return r.do(ctx, func() (*Response, bool, error) {
+		resp, err := r.Provider.Complete(ctx, req)
+		return resp, false, err
+	})
+}
+
+// Stream retries only while nothing has been streamed yet; once text has
+// reached the caller a retry would repeat it.
+func (r *retrying) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	streamer, ok := r.Provider.(Streamer)
+	if !ok {
+		return completeAsStream(ctx, req, onChunk, r.Complete)
+	}
+
+	return r.do(ctx, func() (*Response, bool, error) {
+		started := false
+		resp, err := streamer.Stream(ctx, req, func(chunk string) {
+			started = true
+			onChunk(chunk)
+		})
+		return resp, started, err
+	})
+}
+
+// do runs call until it succeeds, fails permanently, or runs out of
+// attempts. call reports whether its failure happened after output was
+// produced, in which case it is not retried.
+func (r *retrying) do(ctx context.Context, call func() (*Response, bool, error)) (*Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, started, err := call()
+		if err == nil || started || attempt >= r.policy.MaxAttempts || !isRetryable(ctx, err) {
+			return resp, err
+		}
+
+		wait := r.backoff(attempt, err)
+		if r.policy.OnRetry != nil {
+			r.policy.OnRetry(attempt+1, wait, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns how long to wait before the next attempt. A Retry-After
+// from the provider wins; otherwise it is exponential with jitter.
+func (r *retrying) backoff(attempt int, err error) time.Duration {
+	if wait := retryAfter(err); wait > 0 {
+		return wait
+	}
+
+	delay := r.policy.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > r.policy.MaxDelay {
+		delay = r.policy.MaxDelay
+	}
+	// Jitter between half and the full delay so clients don't retry in step.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfter extracts the provider's requested delay from err, if any.
+func retryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+
+	// Gemini reports the delay in a google.rpc.RetryInfo detail.
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		for _, detail := range apiErr.Details {
+			if detail["@type"] != "type.googleapis.com/google.rpc.RetryInfo" {
+				continue
+			}
+			if delay, ok := detail["retryDelay"].(string); ok {
+				if d, err := time.ParseDuration(delay); err == nil {
+					return d
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// isRetryable reports whether err is worth another attempt: a rate limit, a
+// server error, or a network failure that may not happen again.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.Code)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
@@ -0,0 +1,343 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// pipeFilters are the commands a docker command's output may be piped
+// through. They only read their input, except for the options of sort and
+// uniq that filterArgsOK turns away; sed and awk are left out because they
+// can run commands of their own.
+var pipeFilters = map[string]bool{
+	"grep": true, "egrep": true, "fgrep": true,
+	"head": true, "tail": true, "sort": true, "uniq": true, "wc": true,
+	"cut": true, "tr": true, "column": true, "jq": true,
+}
+
+// safeSubstitutions are the commands other than docker that may appear in
+// $(...), because they only print something about the current user or
+// directory, as in "docker run -v $(pwd):/app".
+var safeSubstitutions = map[string]bool{
+	"pwd": true, "id -u": true, "id -g": true,
+}
+
+// shellSegment is one simple command of a command line.
+type shellSegment struct {
+	text string
+	// piped is set if the segment reads the output of the one before it.
+	piped bool
+}
+
+// shellLine is a command line split the way sh would run it.
+type shellLine struct {
+	segments []shellSegment
+	// substitutions are the commands inside $(...), `...`, <(...) and >(...).
+	substitutions []string
+	// writesFile is set if output is redirected to a file.
+	writesFile bool
+}
+
+// checkCommand checks that command, which is run with sh -c, runs nothing
+// but docker: every command joined with &&, ||, ; or &, and every command
+// substitution, must be a docker command, and piped output may only go to
+// docker, a read-only filter such as grep, or "xargs docker". It reports
+// whether the command writes to a file, so that it can be confirmed first.
+func checkCommand(command string) (writesFile bool, err error) {
+	line, err := splitShell(command)
+	if err != nil {
+		return false, err
+	}
+	for i, seg := range line.segments {
+		if seg.text == "" || isDockerCommand(seg.text) || seg.piped && isPipeFilter(seg.text) {
+			continue
+		}
+		if i == 0 {
+			return false, fmt.Errorf("command is not a docker command: %q", command)
+		}
+		return false, fmt.Errorf("command also runs %q, which is not a docker command", seg.text)
+	}
+	writesFile = line.writesFile
+	for _, sub := range line.substitutions {
+		if safeSubstitutions[strings.Join(strings.Fields(sub), " ")] {
+			continue
+		}
+		writes, err := checkCommand(strings.TrimSpace(sub))
+		if err != nil {
+			return false, fmt.Errorf("in %q: %w", sub, err)
+		}
+		writesFile = writesFile || writes
+	}
+	return writesFile, nil
+}
+
+// isPipeFilter reports whether segment is a filter that docker output may be
+// piped through.
+func isPipeFilter(segment string) bool {
+	fields := strings.Fields(segment)
+	if pipeFilters[fields[0]] {
+		return filterArgsOK(fields[0], fields[1:])
+	}
+	if fields[0] != "xargs" {
+		return false
+	}
+	// xargs runs whatever follows its options, which has to be docker.
+	args := fields[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		option := args[0]
+		args = args[1:]
+		if len(option) == 2 && strings.ContainsRune("IanPLdsE", rune(option[1])) && len(args) > 0 {
+			args = args[1:]
+		}
+	}
+	return isDockerCommand(strings.Join(args, " "))
+}
+
+// filterArgsOK reports whether args keep the filter name from writing a
+// file or running a command: "sort -o FILE" writes its output to FILE,
+// "sort --compress-program=PROG" runs PROG, and "uniq IN OUT" writes to OUT.
+func filterArgsOK(name string, args []string) bool {
+	switch name {
+	case "sort":
+		for _, arg := range args {
+			if arg == "--" {
+				break
+			}
+			if long, ok := strings.CutPrefix(arg, "--"); ok {
+				// GNU sort accepts any unambiguous prefix of a long option.
+				long, _, _ = strings.Cut(long, "=")
+				if strings.HasPrefix("output", long) || strings.HasPrefix("compress-program", long) {
+					return false
+				}
+			} else if strings.HasPrefix(arg, "-") && strings.Contains(arg[1:], "o") {
+				return false
+			}
+		}
+	case "uniq":
+		inputs := 0
+		for i := 0; i < len(args); i++ {
+			switch arg := args[i]; {
+			case arg == "--":
+				inputs += len(args) - i - 1
+				i = len(args)
+			case arg == "-f" || arg == "-s" || arg == "-w":
+				i++
+			case len(arg) > 1 && strings.HasPrefix(arg, "-"):
+			default:
+				inputs++
+			}
+		}
+		return inputs <= 1
+	}
+	return true
+}
+
+// splitShell splits a command line into its simple commands, following sh's
+// quoting rules closely enough to find every command it would run.
+func splitShell(s string) (*shellLine, error) {
+	line := &shellLine{}
+	var cur strings.Builder
+	piped := false
+	flush := func(nextPiped bool) {
+		line.segments = append(line.segments, shellSegment{text: strings.TrimSpace(cur.String()), piped: piped})
+		cur.Reset()
+		piped = nextPiped
+	}
+
+	inSingle, inDouble := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inSingle:
+			inSingle = c != '\''
+		case c == '\\' && i+1 < len(s):
+			cur.WriteByte(c)
+			i++
+			c = s[i]
+		case c == '$' && i+1 < len(s) && s[i+1] == '(',
+			!inDouble && (c == '<' || c == '>') && i+1 < len(s) && s[i+1] == '(':
+			end, err := closingParen(s, i+1)
+			if err != nil {
+				return nil, err
+			}
+			line.substitutions = append(line.substitutions, s[i+2:end])
+			cur.WriteString(s[i : end+1])
+			i = end
+			continue
+		case c == '`':
+			end := strings.IndexByte(s[i+1:], '`')
+			if end < 0 {
+				return nil, errors.New("command has an unclosed backquote")
+			}
+			line.substitutions = append(line.substitutions, s[i+1:i+1+end])
+			cur.WriteString(s[i : i+end+2])
+			i += end + 1
+			continue
+		case inDouble:
+			inDouble = c != '"'
+		case c == '"':
+			inDouble = true
+		case c == '\'':
+			inSingle = true
+		case c == '>':
+			// ">&2" and "2>&1" duplicate a descriptor; anything else but
+			// /dev/null is a file.
+			j := i + 1
+			if j < len(s) && (s[j] == '>' || s[j] == '|') {
+				j++
+			}
+			if j < len(s) && s[j] == '&' {
+				cur.WriteString(s[i : j+1])
+				i = j
+				continue
+			}
+			if target := redirectTarget(s[j:]); target != "/dev/null" {
+				line.writesFile = true
+			}
+		case c == ';' || c == '\n':
+			flush(false)
+			continue
+		case c == '&':
+			if i+1 < len(s) && s[i+1] == '&' {
+				i++
+			}
+			flush(false)
+			continue
+		case c == '|':
+			if i+1 < len(s) && s[i+1] == '|' {
+				i++
+				flush(false)
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '&' {
+				i++
+			}
+			flush(true)
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	if inSingle || inDouble {
+		return nil, errors.New("command has an unclosed quote")
+	}
+	flush(false)
+	return line, nil
+}
+
+// closingParen returns the index of the parenthesis that closes the one at
+// open, skipping quoted text.
+func closingParen(s string, open int) (int, error) {
+	depth := 0
+	inSingle, inDouble := false, false
+	for i := open; i < len(s); i++ {
+		switch c := s[i]; {
+		case inSingle:
+			inSingle = c != '\''
+		case c == '\\':
+			i++
+		case inDouble:
+			inDouble = c != '"'
+		case c == '\'':
+			inSingle = true
+		case c == '"':
+			inDouble = true
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, errors.New("command has an unclosed parenthesis")
+}
+
+// redirectTarget returns the word a redirection operator is followed by.
+func redirectTarget(s string) string {
+	s = strings.TrimLeft(s, " \t")
+	if end := strings.IndexAny(s, " \t;&|<>()\n"); end >= 0 {
+		s = s[:end]
+	}
+	return strings.Trim(s, `"'`)
+}
+
+// readOnlyCommands are the docker subcommands that only show something.
+// Management commands are listed with their subcommand, as in
+// "container ls".
+var readOnlyCommands = map[string]bool{
+	"ps": true, "images": true, "logs": true, "inspect": true, "stats": true,
+	"top": true, "port": true, "diff": true, "events": true, "history": true,
+	"search": true, "version": true, "info": true,
+
+	"container ls": true, "container list": true, "container ps": true,
+	"container inspect": true, "container logs": true, "container stats": true,
+	"container top": true, "container port": true, "container diff": true,
+	"image ls": true, "image list": true, "image inspect": true, "image history": true,
+	"volume ls": true, "volume list": true, "volume inspect": true,
+	"network ls": true, "network list": true, "network inspect": true,
+	"system df": true, "system info": true, "system events": true,
+	"context ls": true, "context list": true, "context show": true, "context inspect": true,
+	"compose ps": true, "compose ls": true, "compose logs": true, "compose config": true,
+	"compose top": true, "compose images": true, "compose port": true, "compose version": true,
+	"scout cves": true, "scout quickview": true, "scout recommendations": true, "scout compare": true,
+	"buildx ls": true, "buildx inspect": true, "buildx du": true,
+	"model ls": true, "model list": true, "model inspect": true, "model status": true,
+}
+
+// optionsWithValues are the docker and docker compose options, before the
+// subcommand, whose value is the next word.
+var optionsWithValues = map[string]bool{
+	"-c": true, "--context": true, "-H": true, "--host": true, "--config": true,
+	"-l": true, "--log-level": true,
+	"-f": true, "--file": true, "-p": true, "--project-name": true, "--profile": true,
+	"--env-file": true, "--project-directory": true, "--ansi": true, "--progress": true,
+}
+
+// ChangesState reports whether command runs any docker command that isn't
+// known to be read-only. A command that can't be parsed is assumed to
+// change state. The model's own risk rating plays no part, since a prompt
+// injection that picks the command can pick its rating too.
+func ChangesState(command string) bool {
+	line, err := splitShell(command)
+	if err != nil {
+		return true
+	}
+	for _, seg := range line.segments {
+		fields := strings.Fields(seg.text)
+		// "xargs docker rm" runs the docker command after xargs's options.
+		if i := slices.IndexFunc(fields, func(f string) bool { return f == "docker" || f == "docker-compose" }); i >= 0 && !readOnlyDocker(fields[i:]) {
+			return true
+		}
+	}
+	for _, sub := range line.substitutions {
+		if ChangesState(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// readOnlyDocker reports whether the docker command line in fields is in
+// readOnlyCommands.
+func readOnlyDocker(fields []string) bool {
+	var words []string
+	if fields[0] == "docker-compose" {
+		words = append(words, "compose")
+	}
+	for i := 1; i < len(fields) && len(words) < 2; i++ {
+		field := fields[i]
+		switch {
+		case optionsWithValues[field]:
+			i++
+		case strings.HasPrefix(field, "-"):
+		default:
+			words = append(words, field)
+		}
+	}
+	if len(words) == 0 {
+		return true
+	}
+	return readOnlyCommands[words[0]] || len(words) == 2 && readOnlyCommands[words[0]+" "+words[1]]
+}
@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// readSSE reads a server-sent events stream and calls fn with the event name
+// and data of each event. It stops at the end of the stream or when fn
+// returns an error.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var event string
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := fn(event, strings.Join(data, "\n"))
+		event, data = "", nil
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comment, used by some servers as a keep-alive.
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return dispatch()
+}
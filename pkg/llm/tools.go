@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaxToolRounds caps how many rounds of tool calls the model may make
+// before it must answer.
+const MaxToolRounds = 3
+
+// ToolDef describes a function the model may call.
+type ToolDef struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments object.
+	Parameters map[string]interface{}
+}
+
+// ToolCall is a request from the model to run a tool.
+type ToolCall struct {
+	ID   string
+	Name string
+	Args map[string]interface{}
+}
+
+// ToolSet is a fixed set of tools the model may call while answering.
+type ToolSet interface {
+	Definitions() []ToolDef
+	// Call runs the named tool and returns its output for the model.
+	Call(ctx context.Context, name string, args map[string]interface{}) (string, error)
+}
+
+// runToolCalls executes the calls and returns the assistant message that
+// requested them followed by one tool message per result. A failing tool
+// reports its error to the model rather than aborting the request. Output
+// is fenced as untrusted data, since logs and labels can say anything.
+func runToolCalls(ctx context.Context, tools ToolSet, content string, calls []ToolCall, onCall func(ToolCall)) []Message {
+	messages := []Message{{Role: RoleAssistant, Content: content, ToolCalls: calls}}
+	for _, call := range calls {
+		if onCall != nil {
+			onCall(call)
+		}
+		output, err := tools.Call(ctx, call.Name, call.Args)
+		if err != nil {
+			output = fmt.Sprintf("error: %v", err)
+		}
+		messages = append(messages, Message{
+			Role:       RoleTool,
+			Content:    fenceUntrusted(output),
+			ToolCallID: call.ID,
+			Name:       call.Name,
+		})
+	}
+	return messages
+}
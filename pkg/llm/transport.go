@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// TransportOptions are the network settings for reaching a provider, such
+// as those needed behind a corporate proxy that intercepts TLS.
+type TransportOptions struct {
+	// Proxy is the URL of the proxy to send requests through. Empty means
+	// the HTTPS_PROXY and NO_PROXY environment variables decide.
+	Proxy string
+	// CAFile is a PEM bundle of certificates to trust in addition to the
+	// system's.
+	CAFile string
+	// ClientCert and ClientKey are the PEM files of a client certificate.
+	ClientCert string
+	ClientKey  string
+	// InsecureSkipVerify turns off TLS certificate checks altogether.
+	InsecureSkipVerify bool
+}
+
+// NewTransport returns an HTTP transport with the settings in o. It returns
+// http.DefaultTransport if there are none.
+func NewTransport(o TransportOptions) (http.RoundTripper, error) {
+	if o == (TransportOptions{}) {
+		return http.DefaultTransport, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if o.Proxy != "" {
+		proxyURL, err := url.Parse(o.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", o.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", o.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if o.ClientCert != "" || o.ClientKey != "" {
+		if o.ClientCert == "" || o.ClientKey == "" {
+			return nil, errors.New("a client certificate needs both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// headerTransport adds fixed headers to every request.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUnmentionedTargets(t *testing.T) {
+	targets := []Target{
+		{Names: []string{"web"}, ID: "3f2a1b9c0d1e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8"},
+		{Names: []string{"db"}, ID: "9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d"},
+		{Names: []string{"nginx:latest", "nginx"}, ID: "a1b2c3d4e5f6"},
+		{Names: []string{"pgdata"}},
+		{Names: []string{"backend"}},
+	}
+	tests := []struct {
+		command   string
+		mentioned string
+		want      []string
+	}{
+		{command: "docker restart web", mentioned: "restart web", want: nil},
+		{command: "docker restart web", mentioned: "restart the website", want: []string{"web"}},
+		{command: "docker rm -f db web", mentioned: "remove web.", want: []string{"db"}},
+		{command: "docker ps --filter name=db", mentioned: "is it up?", want: []string{"db"}},
+		{command: "docker cp db:/var/log/x .", mentioned: "copy the log", want: []string{"db"}},
+		{command: "docker rm -f 3f2a1b9c0d1e", mentioned: "clean up", want: []string{"3f2a1b9c0d1e"}},
+		{command: "docker rm -f 3f2a1b9c0d1e", mentioned: "remove web", want: nil},
+		{command: "docker stop 3f2a", mentioned: "stop 3f2a", want: nil},
+		{command: "docker volume rm pgdata", mentioned: "free some space", want: []string{"pgdata"}},
+		{command: "docker rmi nginx:latest", mentioned: "remove the nginx image", want: nil},
+		{command: "docker rmi a1b2c3d4e5f6", mentioned: "remove unused images", want: []string{"a1b2c3d4e5f6"}},
+		{command: "docker network rm backend", mentioned: "drop the backend network", want: nil},
+		{command: "docker run --rm alpine echo add", mentioned: "say add", want: nil},
+	}
+	for _, tt := range tests {
+		got := UnmentionedTargets(tt.command, targets, tt.mentioned)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("UnmentionedTargets(%q, mentioned %q) = %q, want %q", tt.command, tt.mentioned, got, tt.want)
+		}
+	}
+}
+
+func TestMentionedLeavesOutCommands(t *testing.T) {
+	c := NewConversation(0)
+	c.Add(Turn{Request: "show the logs", Command: "docker logs db", ExitCode: 0})
+	if got := UnmentionedTargets("docker rm -f db", []Target{{Names: []string{"db"}}}, c.Mentioned()); !slices.Equal(got, []string{"db"}) {
+		t.Errorf("a name from an earlier command counted as mentioned: got %q", got)
+	}
+}
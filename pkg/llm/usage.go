@@ -0,0 +1,48 @@
+package llm
+
+import "context"
+
+// metered wraps a Provider and reports the token usage of every call.
+type metered struct {
+	Provider
+	onUsage func(provider string, usage Usage)
+}
+
+// WithUsage returns a Provider that calls onUsage after each successful call
+// that reported token counts, including conversation summaries and tool
+// rounds. Wrap it around any fallback chain so provider names the link that
+// answered.
+func WithUsage(p Provider, onUsage func(provider string, usage Usage)) Provider {
+	return &metered{Provider: p, onUsage: onUsage}
+}
+
+func (m *metered) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := m.Provider.Complete(ctx, req)
+	m.report(req, resp, err)
+	return resp, err
+}
+
+func (m *metered) Stream(ctx context.Context, req Request, onChunk func(string)) (*Response, error) {
+	streamer, ok := m.Provider.(Streamer)
+	if !ok {
+		return completeAsStream(ctx, req, onChunk, m.Complete)
+	}
+	resp, err := streamer.Stream(ctx, req, onChunk)
+	m.report(req, resp, err)
+	return resp, err
+}
+
+func (m *metered) report(req Request, resp *Response, err error) {
+	if err != nil || resp.Usage == nil {
+		return
+	}
+	usage := *resp.Usage
+	provider, model := resp.Provider, resp.Model
+	if provider == "" {
+		provider, model = m.Provider.Name(), req.Model
+	}
+	if usage.Model == "" {
+		usage.Model = model
+	}
+	m.onUsage(provider, usage)
+}
@@ -0,0 +1,214 @@
+// Package usage counts the tokens docker-ai spends and what they cost.
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"docker-ai/pkg/llm"
+)
+
+// DefaultPrices holds the prices in llm.Catalog. Entries in the config
+// file's "prices" override them.
+var DefaultPrices = catalogPrices()
+
+func catalogPrices() map[string]llm.Price {
+	prices := map[string]llm.Price{}
+	for _, m := range llm.Catalog {
+		if m.Price != nil {
+			prices[m.Name] = *m.Price
+		}
+	}
+	return prices
+}
+
+// Totals are the accumulated usage of one provider and model.
+type Totals struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	// Cost is in US dollars. Unpriced is set if some calls were for a
+	// model with no known price, so Cost undercounts.
+	Cost     float64 `json:"cost"`
+	Unpriced bool    `json:"unpriced,omitempty"`
+}
+
+func (t *Totals) add(o Totals) {
+	t.Calls += o.Calls
+	t.PromptTokens += o.PromptTokens
+	t.CompletionTokens += o.CompletionTokens
+	t.Cost += o.Cost
+	t.Unpriced = t.Unpriced || o.Unpriced
+}
+
+// Tracker accumulates the usage of a session and adds each call to the
+// daily totals on disk.
+type Tracker struct {
+	mu      sync.Mutex
+	prices  map[string]llm.Price
+	path    string
+	session map[string]*Totals
+}
+
+// GetUsagePath returns ~/.docker-ai-usage.json, where daily totals are kept.
+func GetUsagePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker-ai-usage.json"), nil
+}
+
+// NewTracker returns a tracker that prices calls with DefaultPrices
+// overridden by prices, and keeps daily totals in path. An empty path keeps
+// nothing on disk.
+func NewTracker(prices map[string]llm.Price, path string) *Tracker {
+	merged := make(map[string]llm.Price, len(DefaultPrices)+len(prices))
+	for model, price := range DefaultPrices {
+		merged[model] = price
+	}
+	for model, price := range prices {
+		merged[model] = price
+	}
+	return &Tracker{prices: merged, path: path, session: map[string]*Totals{}}
+}
+
+// price looks up model, falling back to the longest priced prefix so that
+// dated versions such as "gpt-4o-2024-08-06" match "gpt-4o". Local models
+// served by Ollama are free.
+func (t *Tracker) price(provider, model string) (llm.Price, bool) {
+	if provider == "ollama" {
+		return llm.Price{}, true
+	}
+	if price, ok := t.prices[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range t.prices {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return llm.Price{}, false
+	}
+	return t.prices[best], true
+}
+
+// Add records one call. The session totals are always updated; the error
+// only reports a failure to update the daily totals.
+func (t *Tracker) Add(provider string, u llm.Usage) error {
+	price, priced := t.price(provider, u.Model)
+	call := Totals{
+		Calls:            1,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		Cost:             (float64(u.PromptTokens)*price.Input + float64(u.CompletionTokens)*price.Output) / 1e6,
+		Unpriced:         !priced,
+	}
+	key := provider + "/" + u.Model
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.session[key] == nil {
+		t.session[key] = &Totals{}
+	}
+	t.session[key].add(call)
+
+	if t.path == "" {
+		return nil
+	}
+	days, err := Load(t.path)
+	if err != nil {
+		return err
+	}
+	today := time.Now().Format(time.DateOnly)
+	if days[today] == nil {
+		days[today] = map[string]*Totals{}
+	}
+	if days[today][key] == nil {
+		days[today][key] = &Totals{}
+	}
+	days[today][key].add(call)
+
+	data, err := json.MarshalIndent(days, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, data, 0o600)
+}
+
+// Days maps a date (YYYY-MM-DD) to the totals per "provider/model".
+type Days map[string]map[string]*Totals
+
+// Load reads the daily totals in path. A missing file has none.
+func Load(path string) (Days, error) {
+	days := Days{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return days, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &days); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return days, nil
+}
+
+// Report writes the session totals as a table.
+func (t *Tracker) Report(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.session) == 0 {
+		fmt.Fprintln(w, "No tokens used this session.")
+		return
+	}
+	fmt.Fprintln(w, "Token usage this session:")
+	WriteTable(w, t.session)
+}
+
+// WriteTable writes one row per "provider/model" and a total.
+func WriteTable(w io.Writer, totals map[string]*Totals) {
+	keys := make([]string, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tCALLS\tPROMPT\tCOMPLETION\tCOST")
+	var sum Totals
+	for _, key := range keys {
+		t := totals[key]
+		sum.add(*t)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", key, t.Calls, t.PromptTokens, t.CompletionTokens, formatCost(*t))
+	}
+	if len(keys) > 1 {
+		fmt.Fprintf(tw, "total\t%d\t%d\t%d\t%s\n", sum.Calls, sum.PromptTokens, sum.CompletionTokens, formatCost(sum))
+	}
+	tw.Flush()
+}
+
+// formatCost renders a cost, marking it when some calls had no price.
+func formatCost(t Totals) string {
+	cost := fmt.Sprintf("$%.4f", t.Cost)
+	if t.Unpriced {
+		if t.Cost == 0 {
+			return "unknown"
+		}
+		cost += " (partly unknown)"
+	}
+	return cost
+}